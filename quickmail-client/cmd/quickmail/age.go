@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"quickmail/core"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// age is a lighter alternative to PGP. "Encrypt (age)" replaces the body in
+// the text area with armored age ciphertext for the recipients listed in
+// age_recipients_path, and "Decrypt (age)" reverses it with the identities
+// in age_identity_path.
+
+// readAgeRecipients reads a recipients file with one age public key per
+// line; empty lines and lines starting with # are ignored
+func readAgeRecipients(path string) ([]age.Recipient, error) {
+	if path == "" {
+		return nil, errors.New("no age recipients configured (age_recipients_path)")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read age recipients: %w", err)
+	}
+	defer f.Close()
+
+	recipients, err := age.ParseRecipients(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse age recipients: %w", err)
+	}
+	return recipients, nil
+}
+
+// readAgeIdentities reads an age identity file as written by age-keygen
+func readAgeIdentities(path string) ([]age.Identity, error) {
+	if path == "" {
+		return nil, errors.New("no age identity configured (age_identity_path)")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read age identity: %w", err)
+	}
+	defer f.Close()
+
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse age identity: %w", err)
+	}
+	return identities, nil
+}
+
+// ageEncrypt returns plaintext encrypted to the recipients as an armored
+// age file
+func ageEncrypt(plaintext string, recipients ...age.Recipient) (string, error) {
+	var buf bytes.Buffer
+	armored := armor.NewWriter(&buf)
+
+	w, err := age.Encrypt(armored, recipients...)
+	if err != nil {
+		return "", fmt.Errorf("could not encrypt message: %w", err)
+	}
+	if _, err := io.WriteString(w, plaintext); err != nil {
+		return "", fmt.Errorf("could not encrypt message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("could not encrypt message: %w", err)
+	}
+	if err := armored.Close(); err != nil {
+		return "", fmt.Errorf("could not encrypt message: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// ageDecrypt decrypts the armored age file in text with the identities.
+// Text before and after the armor, such as message headers, is kept.
+func ageDecrypt(text string, identities ...age.Identity) (string, error) {
+	start := strings.Index(text, armor.Header)
+	if start < 0 {
+		return "", errors.New("no age encrypted block found")
+	}
+	end := strings.Index(text[start:], armor.Footer)
+	if end < 0 {
+		return "", errors.New("the age encrypted block is incomplete")
+	}
+	end += start + len(armor.Footer)
+
+	r, err := age.Decrypt(armor.NewReader(strings.NewReader(text[start:end])), identities...)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return "", errors.New("the message was not encrypted to your age identity")
+		}
+		return "", fmt.Errorf("could not decrypt message: %w", err)
+	}
+
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("could not decrypt message: %w", err)
+	}
+
+	return text[:start] + string(plaintext) + strings.TrimPrefix(text[end:], "\n"), nil
+}
+
+// encryptAge replaces the body in the text area with age ciphertext,
+// leaving the headers readable for the server. Text without headers is
+// encrypted as a whole.
+func (q *QuickMail) encryptAge() {
+	if q.config == nil {
+		q.showError("Configuration not loaded")
+		return
+	}
+
+	recipients, err := readAgeRecipients(q.config.AgeRecipientsPath)
+	if err != nil {
+		q.showError(fmt.Sprintf("age error: %v", err))
+		return
+	}
+
+	headers, body, _ := core.SplitMessage(q.textArea.Text)
+	if strings.TrimSpace(body) == "" {
+		q.showError("Message has no body to encrypt")
+		return
+	}
+
+	encrypted, err := ageEncrypt(body, recipients...)
+	if err != nil {
+		q.showError(fmt.Sprintf("age error: %v", err))
+		return
+	}
+	q.textArea.SetText(core.JoinMessage(headers, encrypted))
+}
+
+// decryptAge decrypts an age encrypted block in the text area
+func (q *QuickMail) decryptAge() {
+	if q.config == nil {
+		q.showError("Configuration not loaded")
+		return
+	}
+
+	identities, err := readAgeIdentities(q.config.AgeIdentityPath)
+	if err != nil {
+		q.showError(fmt.Sprintf("age error: %v", err))
+		return
+	}
+
+	decrypted, err := ageDecrypt(strings.ReplaceAll(q.textArea.Text, "\r\n", "\n"), identities...)
+	if err != nil {
+		q.showError(fmt.Sprintf("age error: %v", err))
+		return
+	}
+	q.textArea.SetText(decrypted)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// newTestAgeKey generates an age identity and writes it and its recipient
+// to files as age-keygen would, returning the paths
+func newTestAgeKey(t *testing.T) (identityPath, recipientsPath string) {
+	t.Helper()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	identityPath = filepath.Join(dir, "key.txt")
+	recipientsPath = filepath.Join(dir, "recipients.txt")
+	key := "# created: for a test\n# public key: " + identity.Recipient().String() + "\n" + identity.String() + "\n"
+	recipients := "# alice\n\n" + identity.Recipient().String() + "\n"
+	if err := os.WriteFile(identityPath, []byte(key), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(recipientsPath, []byte(recipients), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return identityPath, recipientsPath
+}
+
+func TestAgeRoundTrip(t *testing.T) {
+	identityPath, recipientsPath := newTestAgeKey(t)
+	recipients, err := readAgeRecipients(recipientsPath)
+	if err != nil {
+		t.Fatalf("readAgeRecipients: %v", err)
+	}
+	identities, err := readAgeIdentities(identityPath)
+	if err != nil {
+		t.Fatalf("readAgeIdentities: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		plaintext string
+	}{
+		{"ascii", "Hello, World!\n"},
+		{"utf-8", "Grüße aus Köln 🔒\n"},
+		{"no trailing newline", "line one\nline two"},
+		{"empty", ""},
+		{"large", strings.Repeat("0123456789abcdef", 8192)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			armored, err := ageEncrypt(tt.plaintext, recipients...)
+			if err != nil {
+				t.Fatalf("ageEncrypt: %v", err)
+			}
+			if !strings.HasPrefix(armored, armor.Header) {
+				t.Errorf("output is not armored: %q", armored)
+			}
+
+			plaintext, err := ageDecrypt(armored, identities...)
+			if err != nil {
+				t.Fatalf("ageDecrypt: %v", err)
+			}
+			if plaintext != tt.plaintext {
+				t.Errorf("decrypted text differs: got %d bytes, want %d", len(plaintext), len(tt.plaintext))
+			}
+		})
+	}
+}
+
+func TestAgeDecryptKeepsSurroundingText(t *testing.T) {
+	identityPath, recipientsPath := newTestAgeKey(t)
+	recipients, _ := readAgeRecipients(recipientsPath)
+	identities, _ := readAgeIdentities(identityPath)
+
+	armored, err := ageEncrypt("secret body\n", recipients...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := "To: alice@example.org\nSubject: Hi\n\n" + armored + "-- \nsignature\n"
+
+	got, err := ageDecrypt(message, identities...)
+	if err != nil {
+		t.Fatalf("ageDecrypt: %v", err)
+	}
+	if want := "To: alice@example.org\nSubject: Hi\n\nsecret body\n-- \nsignature\n"; got != want {
+		t.Errorf("ageDecrypt = %q, want %q", got, want)
+	}
+}
+
+func TestAgeDecryptErrors(t *testing.T) {
+	_, recipientsPath := newTestAgeKey(t)
+	otherIdentityPath, _ := newTestAgeKey(t)
+	recipients, _ := readAgeRecipients(recipientsPath)
+	otherIdentities, _ := readAgeIdentities(otherIdentityPath)
+
+	armored, err := ageEncrypt("secret\n", recipients...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"wrong identity", armored, "not encrypted to your age identity"},
+		{"no block", "just text\n", "no age encrypted block"},
+		{"incomplete block", strings.TrimSuffix(armored, armor.Footer+"\n"), "incomplete"},
+	}
+
+	for _, tt := range tests {
+		_, err := ageDecrypt(tt.text, otherIdentities...)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: ageDecrypt error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestReadAgeKeyFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	garbage := filepath.Join(dir, "garbage.txt")
+	if err := os.WriteFile(garbage, []byte("not a key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.txt")
+
+	tests := []struct {
+		name string
+		read func(string) error
+		path string
+		want string
+	}{
+		{"recipients unset", readRecipientsErr, "", "no age recipients configured"},
+		{"recipients missing", readRecipientsErr, missing, "could not read age recipients"},
+		{"recipients garbage", readRecipientsErr, garbage, "could not parse age recipients"},
+		{"identity unset", readIdentitiesErr, "", "no age identity configured"},
+		{"identity missing", readIdentitiesErr, missing, "could not read age identity"},
+		{"identity garbage", readIdentitiesErr, garbage, "could not parse age identity"},
+	}
+
+	for _, tt := range tests {
+		if err := tt.read(tt.path); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func readRecipientsErr(path string) error {
+	_, err := readAgeRecipients(path)
+	return err
+}
+
+func readIdentitiesErr(path string) error {
+	_, err := readAgeIdentities(path)
+	return err
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image/color"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Messages with attachments are uploaded as a MIME multipart/mixed
+// message: the header block gets "MIME-Version: 1.0" and
+// "Content-Type: multipart/mixed; boundary=...", the composed text is the
+// first part and every attachment follows as a base64 encoded part with a
+// Content-Disposition filename. The server keeps a Content-Type header it
+// finds, so it relays the message unchanged.
+
+// writeBase64Lines writes data base64 encoded in lines of 76 characters
+func writeBase64Lines(w *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		w.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	if encoded != "" {
+		w.WriteString(encoded + "\r\n")
+	}
+}
+
+// attachmentContentType guesses the Content-Type from the file extension
+// and adds the file name as the name parameter
+func attachmentContentType(path string) string {
+	mediaType, params, err := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(path)))
+	if err != nil {
+		mediaType, params = "application/octet-stream", map[string]string{}
+	}
+	params["name"] = filepath.Base(path)
+	return mime.FormatMediaType(mediaType, params)
+}
+
+// buildMultipart turns a composed message and a list of files into a
+// multipart/mixed MIME message with the text as the first part
+func buildMultipart(message string, attachments []string) (string, error) {
+	headers, body, _ := core.SplitMessage(message)
+	headers, textType, textEncoding := core.ExtractMIMEHeaders(headers)
+	if textType == "" {
+		textType = "text/plain; charset=UTF-8"
+	}
+	if textEncoding == "" {
+		textEncoding = "8bit"
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	textPart, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {textType},
+		"Content-Transfer-Encoding": {textEncoding},
+	})
+	if err != nil {
+		return "", err
+	}
+	if _, err := textPart.Write([]byte(body)); err != nil {
+		return "", err
+	}
+
+	for _, path := range attachments {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("could not read attachment: %w", err)
+		}
+
+		name := filepath.Base(path)
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {attachmentContentType(path)},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": name})},
+		})
+		if err != nil {
+			return "", err
+		}
+
+		var encoded bytes.Buffer
+		writeBase64Lines(&encoded, data)
+		if _, err := part.Write(encoded.Bytes()); err != nil {
+			return "", err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	var result strings.Builder
+	if headers != "" {
+		result.WriteString(headers + "\n")
+	}
+	result.WriteString("MIME-Version: 1.0\n")
+	result.WriteString("Content-Type: multipart/mixed; boundary=\"" + writer.Boundary() + "\"\n")
+	result.WriteString("\n")
+	result.WriteString("This is a multi-part message in MIME format.\n")
+	result.Write(buf.Bytes())
+
+	return result.String(), nil
+}
+
+// attachmentsSize returns the total size of the files at paths
+func attachmentsSize(paths []string) (int64, error) {
+	var total int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		total += info.Size()
+	}
+	return total, nil
+}
+
+// checkAttachmentSize returns an error if the files at paths are larger in
+// total than max_attachment_bytes
+func (q *QuickMail) checkAttachmentSize(paths []string) error {
+	if q.config == nil || q.config.MaxAttachmentBytes <= 0 {
+		return nil
+	}
+	total, err := attachmentsSize(paths)
+	if err != nil {
+		return err
+	}
+	if limit := int64(q.config.MaxAttachmentBytes); total > limit {
+		return fmt.Errorf("the attachments are %d bytes in total, more than the limit of %d bytes", total, limit)
+	}
+	return nil
+}
+
+// newAttachmentList creates the list of attached files shown below the
+// text area, hidden while there are no attachments. Every file has a
+// button removing it from the message.
+func (q *QuickMail) newAttachmentList() fyne.CanvasObject {
+	q.attachmentList = widget.NewList(
+		func() int {
+			return len(q.attachments)
+		},
+		func() fyne.CanvasObject {
+			remove := widget.NewButtonWithIcon("", theme.ContentRemoveIcon(), nil)
+			remove.Importance = widget.LowImportance
+			return container.NewBorder(nil, nil, nil, remove, widget.NewLabel(""))
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			row := item.(*fyne.Container)
+			row.Objects[0].(*widget.Label).SetText(q.attachments[id])
+			row.Objects[1].(*widget.Button).OnTapped = func() {
+				q.removeAttachment(id)
+			}
+		},
+	)
+
+	spacer := canvas.NewRectangle(color.Transparent)
+	spacer.SetMinSize(fyne.NewSize(0, 90))
+
+	q.attachmentBox = container.NewStack(spacer, q.attachmentList)
+	q.attachmentBox.Hide()
+	return q.attachmentBox
+}
+
+// refreshAttachments updates the attachment list after a change
+func (q *QuickMail) refreshAttachments() {
+	if q.attachmentList == nil {
+		return
+	}
+	q.attachmentList.Refresh()
+	if len(q.attachments) == 0 {
+		q.attachmentBox.Hide()
+	} else {
+		q.attachmentBox.Show()
+	}
+}
+
+// removeAttachment removes the attachment at index from the message
+func (q *QuickMail) removeAttachment(index int) {
+	if index < 0 || index >= len(q.attachments) {
+		return
+	}
+	q.attachments = append(q.attachments[:index], q.attachments[index+1:]...)
+	q.attachmentList.UnselectAll()
+	q.refreshAttachments()
+}
+
+// showAttachDialog lets the user pick a file to attach to the message
+func (q *QuickMail) showAttachDialog() {
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			q.showError(fmt.Sprintf("Could not open file: %v", err))
+			return
+		}
+		if reader == nil {
+			return
+		}
+		reader.Close()
+
+		path := reader.URI().Path()
+		attachments := append(q.attachments[:len(q.attachments):len(q.attachments)], path)
+		if err := q.checkAttachmentSize(attachments); err != nil {
+			q.showError(fmt.Sprintf("Could not attach %s: %v", filepath.Base(path), err))
+			return
+		}
+		q.attachments = attachments
+		q.refreshAttachments()
+	}, q.window)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildMultipart(t *testing.T) {
+	dir := t.TempDir()
+	notes := filepath.Join(dir, "notes.txt")
+	photo := filepath.Join(dir, "photo.png")
+	// Long enough to need several base64 lines
+	photoData := bytes.Repeat([]byte{0x89, 'P', 'N', 'G', 0x00, 0xff}, 50)
+	if err := os.WriteFile(notes, []byte("remember the milk\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(photo, photoData, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		message      string
+		attachments  []string
+		subject      string
+		textType     string
+		textEncoding string
+		text         string
+	}{
+		{
+			name:         "headers and body",
+			message:      "To: alice@example.org\nSubject: Notes\n\nSee attached.\n",
+			attachments:  []string{notes},
+			subject:      "Notes",
+			textType:     "text/plain; charset=UTF-8",
+			textEncoding: "8bit",
+			text:         "See attached.\n",
+		},
+		{
+			name:         "text type moves into the part",
+			message:      "Subject: Latin\nContent-Type: text/plain; charset=ISO-8859-1\nContent-Transfer-Encoding: quoted-printable\n\nGr=FC=DFe\n",
+			attachments:  []string{notes, photo},
+			subject:      "Latin",
+			textType:     "text/plain; charset=ISO-8859-1",
+			textEncoding: "quoted-printable",
+			text:         "Gr=FC=DFe\n",
+		},
+		{
+			name:         "no header block",
+			message:      "just a note, no headers\n",
+			attachments:  []string{photo},
+			textType:     "text/plain; charset=UTF-8",
+			textEncoding: "8bit",
+			text:         "just a note, no headers\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			built, err := buildMultipart(tt.message, tt.attachments)
+			if err != nil {
+				t.Fatalf("buildMultipart: %v", err)
+			}
+
+			msg, err := mail.ReadMessage(strings.NewReader(built))
+			if err != nil {
+				t.Fatalf("parsing result: %v\n%s", err, built)
+			}
+			if got := msg.Header.Get("Subject"); got != tt.subject {
+				t.Errorf("Subject = %q, want %q", got, tt.subject)
+			}
+			if got := msg.Header.Get("MIME-Version"); got != "1.0" {
+				t.Errorf("MIME-Version = %q, want 1.0", got)
+			}
+			if got := msg.Header.Get("Content-Transfer-Encoding"); got != "" {
+				t.Errorf("top level Content-Transfer-Encoding = %q, want none", got)
+			}
+			mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+			if err != nil || mediaType != "multipart/mixed" {
+				t.Fatalf("Content-Type = %q, want multipart/mixed", msg.Header.Get("Content-Type"))
+			}
+
+			reader := multipart.NewReader(msg.Body, params["boundary"])
+			text, err := reader.NextRawPart()
+			if err != nil {
+				t.Fatalf("reading text part: %v", err)
+			}
+			if got := text.Header.Get("Content-Type"); got != tt.textType {
+				t.Errorf("text Content-Type = %q, want %q", got, tt.textType)
+			}
+			if got := text.Header.Get("Content-Transfer-Encoding"); got != tt.textEncoding {
+				t.Errorf("text Content-Transfer-Encoding = %q, want %q", got, tt.textEncoding)
+			}
+			if body, _ := io.ReadAll(text); string(body) != tt.text {
+				t.Errorf("text = %q, want %q", body, tt.text)
+			}
+
+			for _, path := range tt.attachments {
+				part, err := reader.NextRawPart()
+				if err != nil {
+					t.Fatalf("reading part for %s: %v", path, err)
+				}
+				if got := part.FileName(); got != filepath.Base(path) {
+					t.Errorf("filename = %q, want %q", got, filepath.Base(path))
+				}
+				if got := part.Header.Get("Content-Transfer-Encoding"); got != "base64" {
+					t.Errorf("%s Content-Transfer-Encoding = %q, want base64", path, got)
+				}
+
+				encoded, _ := io.ReadAll(part)
+				for _, line := range strings.Split(strings.TrimRight(string(encoded), "\r\n"), "\r\n") {
+					if len(line) > 76 {
+						t.Errorf("%s has a base64 line of %d characters", path, len(line))
+					}
+				}
+				data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+				if err != nil {
+					t.Fatalf("decoding %s: %v", path, err)
+				}
+				want, _ := os.ReadFile(path)
+				if !bytes.Equal(data, want) {
+					t.Errorf("%s does not round trip", path)
+				}
+			}
+			if _, err := reader.NextRawPart(); err != io.EOF {
+				t.Errorf("extra part after the attachments: %v", err)
+			}
+		})
+	}
+}
+
+func TestBuildMultipartMissingFile(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.pdf")
+	_, err := buildMultipart("Subject: x\n\nbody\n", []string{missing})
+	if err == nil || !strings.Contains(err.Error(), "could not read attachment") {
+		t.Errorf("buildMultipart error = %v, want a read error", err)
+	}
+}
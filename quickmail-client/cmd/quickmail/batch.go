@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// batchFiles returns the paths of the .txt files in dir in alphabetical
+// order, without descending into subdirectories
+func batchFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.EqualFold(filepath.Ext(entry.Name()), ".txt") {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return paths, nil
+}
+
+// readBatchMessage reads the message in the batch file at path and splits
+// off its Bcc header
+func readBatchMessage(path string) (message string, bcc []string, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return "", nil, errors.New("message is empty")
+	}
+	if !utf8.Valid(data) {
+		return "", nil, errNotUTF8
+	}
+	return core.StripBcc(string(data))
+}
+
+// showBatchSendDialog asks for a directory and sends every .txt file in it
+// to the active server, one after another. The files are sent as they
+// are, like with -send, without the Sign, Encrypt and attachment options
+// of the composed message.
+func (q *QuickMail) showBatchSendDialog() {
+	server := q.activeServer()
+	if server == nil {
+		return
+	}
+
+	dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
+		if err != nil {
+			q.showError(fmt.Sprintf("Could not open folder: %v", err))
+			return
+		}
+		if uri == nil {
+			return
+		}
+
+		dir := uri.Path()
+		paths, err := batchFiles(dir)
+		if err != nil {
+			q.showError(fmt.Sprintf("Could not read %s: %v", dir, err))
+			return
+		}
+		if len(paths) == 0 {
+			q.showInfo("Batch Send", fmt.Sprintf("There are no .txt files in %s.", dir))
+			return
+		}
+
+		text := fmt.Sprintf("Send the %d .txt files in %s to %s?", len(paths), dir, server.BaseURL(q.config.UseTLS))
+		if q.config.ProxyProtocol() == core.ProxyDirect {
+			text += "\n\nproxy_type is direct, so they are sent without Tor."
+		}
+		dialog.ShowConfirm("Batch Send?", text, func(send bool) {
+			if send {
+				q.runBatch(paths, server.UploadURL(q.config.UseTLS))
+			}
+		}, q.window)
+	}, q.window)
+}
+
+// runBatch uploads the files at paths to serverURL in the background,
+// showing the file being sent and a running count in a dialog with a
+// Cancel button. The batch stops at the first failure unless
+// batch_continue_on_error is set.
+func (q *QuickMail) runBatch(paths []string, serverURL string) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	fileLabel := widget.NewLabel("")
+	fileLabel.Truncation = fyne.TextTruncateEllipsis
+	countLabel := widget.NewLabel("")
+	progress := widget.NewProgressBar()
+	progress.Max = float64(len(paths))
+	cancelButton := widget.NewButtonWithIcon("Cancel", theme.CancelIcon(), cancel)
+
+	progressDialog := dialog.NewCustomWithoutButtons("Batch Send",
+		container.NewVBox(fileLabel, progress, countLabel, container.NewCenter(cancelButton)), q.window)
+	progressDialog.Resize(fyne.NewSize(420, 0))
+	progressDialog.Show()
+
+	policy := q.config.RetryPolicy()
+	continueOnError := q.config.BatchContinueOnError
+
+	go func() {
+		defer cancel()
+
+		sent := 0
+		var failures []string
+		for i, path := range paths {
+			name := filepath.Base(path)
+			count := fmt.Sprintf("%d of %d, %d sent, %d failed", i+1, len(paths), sent, len(failures))
+			fyne.Do(func() {
+				fileLabel.SetText("Sending " + name)
+				countLabel.SetText(count)
+				progress.SetValue(float64(i))
+			})
+
+			message, bcc, err := readBatchMessage(path)
+			if err == nil {
+				body := lockMessage(message)
+				err = q.uploadMessage(ctx, serverURL, body.Bytes(), bcc, policy)
+				body.Destroy()
+			}
+			if errors.Is(err, context.Canceled) {
+				fmt.Printf("Batch: %s cancelled\n", name)
+				failures = append(failures, name+": cancelled")
+				break
+			}
+			if err != nil {
+				fmt.Printf("Batch: %s failed: %v\n", name, err)
+				failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+				if !continueOnError {
+					break
+				}
+				continue
+			}
+			fmt.Printf("Batch: %s sent\n", name)
+			sent++
+		}
+
+		fyne.Do(progressDialog.Hide)
+		summary := fmt.Sprintf("%d of %d messages sent.", sent, len(paths))
+		q.setStatus("Batch send: " + summary)
+		if len(failures) == 0 {
+			q.showSuccess(summary)
+			return
+		}
+		if skipped := len(paths) - sent - len(failures); skipped > 0 {
+			summary += fmt.Sprintf(" %d not attempted.", skipped)
+		}
+		q.showError(summary + "\n\n" + strings.Join(failures, "\n"))
+	}()
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"quickmail/core"
+)
+
+// cliOverrides replace settings from quickmail.json for a single
+// headless send; empty fields keep the configured value
+type cliOverrides struct {
+	onionAddress string
+	port         string
+	torProxy     string
+}
+
+// apply validates the overrides and writes them into config. An onion
+// address override replaces the default server profile, creating one if
+// the config has none.
+func (o cliOverrides) apply(config *core.Config) error {
+	if o.torProxy != "" {
+		if err := core.ValidateProxyAddress(o.torProxy); err != nil {
+			return err
+		}
+		config.TorProxy = o.torProxy
+	}
+	if o.port != "" {
+		if err := core.ValidatePort(o.port); err != nil {
+			return err
+		}
+	}
+	if o.onionAddress != "" {
+		if err := core.ValidateServerAddress(o.onionAddress); err != nil {
+			return err
+		}
+	}
+	if o.onionAddress == "" && o.port == "" {
+		return nil
+	}
+
+	server := config.Profile(config.InitialProfile())
+	if server == nil {
+		if o.onionAddress == "" {
+			return errors.New("-port needs a server profile or -onion")
+		}
+		config.Servers = append(config.Servers, core.ServerProfile{Name: core.DefaultProfileName})
+		server = &config.Servers[len(config.Servers)-1]
+		config.ActiveServer = server.Name
+	}
+	if o.onionAddress != "" {
+		server.OnionAddress = o.onionAddress
+	}
+	if o.port != "" {
+		server.Port = o.port
+	}
+	return nil
+}
+
+// sendHeadless reads a message from path, or from stdin if path is empty,
+// and sends it to the default server profile without creating any window,
+// for use in shell pipelines and cron jobs. Without a quickmail.json the
+// defaults of a first run are used, so the flags alone are enough to send.
+// It returns the process exit code.
+func sendHeadless(path string, overrides cliOverrides) int {
+	startTime := time.Now()
+	if err := sendFile(path, overrides); err != nil {
+		fmt.Fprintf(os.Stderr, "quickmail: %v\n", err)
+		return 1
+	}
+	q := &QuickMail{}
+	fmt.Printf("Message sent successfully, elapsed time %s\n", q.formatDuration(time.Since(startTime)))
+	return 0
+}
+
+// readMessage reads the message to send from path, or from stdin if path
+// is empty
+func readMessage(path string) ([]byte, error) {
+	if path == "" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("could not read message from stdin: %w", err)
+		}
+		return data, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read message: %w", err)
+	}
+	return data, nil
+}
+
+// sendFile does the work of sendHeadless
+func sendFile(path string, overrides cliOverrides) error {
+	// Validate only after the overrides, which may fix what the file lacks
+	config, err := core.ReadConfig()
+	if errors.Is(err, core.ErrNoConfig) {
+		config = core.DefaultConfig()
+	} else if err != nil {
+		return err
+	}
+	for _, warning := range config.Warnings() {
+		fmt.Fprintf(os.Stderr, "quickmail: warning: %s\n", warning)
+	}
+	if err := overrides.apply(config); err != nil {
+		return err
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	server := config.Profile(config.InitialProfile())
+	if server == nil {
+		return errors.New("no server profile configured")
+	}
+	if err := server.CheckAddress(); err != nil {
+		if config.Path() == "" {
+			return fmt.Errorf("%w, set it with -onion", err)
+		}
+		return fmt.Errorf("%w, set it in %s or with -onion", err, config.Path())
+	}
+
+	data, err := readMessage(path)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return errors.New("message is empty")
+	}
+
+	message, bcc, err := core.StripBcc(string(data))
+	if err != nil {
+		return err
+	}
+
+	if config.ProxyProtocol() == core.ProxyDirect {
+		fmt.Fprintln(os.Stderr, "quickmail: warning: proxy_type is direct, sending without Tor")
+	}
+
+	body := lockMessage(message)
+	defer body.Destroy()
+
+	q := &QuickMail{config: config}
+	return q.uploadMessage(context.Background(), server.UploadURL(config.UseTLS), body.Bytes(), bcc, config.RetryPolicy())
+}
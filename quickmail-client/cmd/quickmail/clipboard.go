@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/sha256"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+// messageEntry is the multi-line entry the message is composed in. It
+// reports the text it copies or cuts to the clipboard, whether through
+// the keyboard, its context menu or the Edit menu, so that QuickMail can
+// clear it again later.
+type messageEntry struct {
+	widget.Entry
+
+	// onCopy receives the text put on the clipboard
+	onCopy func(text string)
+}
+
+// newMessageEntry returns an empty message entry
+func newMessageEntry() *messageEntry {
+	e := &messageEntry{}
+	e.MultiLine = true
+	e.Wrapping = fyne.TextWrap(fyne.TextTruncateClip)
+	e.ExtendBaseWidget(e)
+	return e
+}
+
+// TypedShortcut handles a shortcut like widget.Entry and then reports
+// copied or cut text
+func (e *messageEntry) TypedShortcut(shortcut fyne.Shortcut) {
+	selected := e.SelectedText()
+	e.Entry.TypedShortcut(shortcut)
+
+	switch shortcut.(type) {
+	case *fyne.ShortcutCopy, *fyne.ShortcutCut:
+		if selected != "" && e.onCopy != nil {
+			e.onCopy(selected)
+		}
+	}
+}
+
+// clipboardCopied starts, or restarts, the timer that clears the clipboard
+// clipboard_clear_seconds after QuickMail put text on it. The clipboard is
+// only cleared if it still holds that text, so content copied in other
+// programs meanwhile is left alone. Only a hash of the text is kept. It
+// runs on the UI thread.
+func (q *QuickMail) clipboardCopied(text string) {
+	if q.clipboardTimer != nil {
+		q.clipboardTimer.Stop()
+		q.clipboardTimer = nil
+	}
+
+	delay := q.config.ClipboardClearDelay()
+	if delay <= 0 {
+		return
+	}
+
+	sum := sha256.Sum256([]byte(text))
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		fyne.Do(func() {
+			if q.clipboardTimer != timer {
+				return
+			}
+			q.clipboardTimer = nil
+
+			clipboard := q.window.Clipboard()
+			if clipboard != nil && sha256.Sum256([]byte(clipboard.Content())) == sum {
+				clipboard.SetContent("")
+			}
+		})
+	})
+	q.clipboardTimer = timer
+}
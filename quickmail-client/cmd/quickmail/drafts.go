@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+// defaultDraftInterval is the autosave interval used when the config does
+// not set one. Saving is cheap since unchanged text is skipped.
+const defaultDraftInterval = 5 * time.Second
+
+// draftPath returns the location of draft.txt
+func (q *QuickMail) draftPath() (string, error) {
+	dir, err := core.DataDir(q.config)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "draft.txt"), nil
+}
+
+// saveDraft writes text to draft.txt unless it is unchanged since the last
+// save; an empty text removes the draft
+func (q *QuickMail) saveDraft(text string) error {
+	q.draftMu.Lock()
+	defer q.draftMu.Unlock()
+
+	if text == q.lastDraft {
+		return nil
+	}
+
+	path, err := q.draftPath()
+	if err != nil {
+		return err
+	}
+
+	if text == "" {
+		err = os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+	} else {
+		err = core.WriteFileAtomic(path, []byte(text), 0600)
+	}
+	if err != nil {
+		return err
+	}
+
+	q.lastDraft = text
+	return nil
+}
+
+// deleteDraft removes draft.txt; text is the current content of the text
+// area, which is not saved again until it changes
+func (q *QuickMail) deleteDraft(text string) {
+	q.draftMu.Lock()
+	defer q.draftMu.Unlock()
+
+	path, err := q.draftPath()
+	if err != nil {
+		return
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Printf("Warning: Could not delete draft: %v\n", err)
+		return
+	}
+	q.lastDraft = text
+}
+
+// draftInterval returns the configured autosave interval, or
+// defaultDraftInterval
+func (q *QuickMail) draftInterval() time.Duration {
+	if q.config != nil && q.config.DraftIntervalSeconds > 0 {
+		return time.Duration(q.config.DraftIntervalSeconds) * time.Second
+	}
+	return defaultDraftInterval
+}
+
+// startDraftAutosave saves the text area to draft.txt at the configured
+// interval unless drafts are disabled. Saving stops when ctx is cancelled.
+func (q *QuickMail) startDraftAutosave(ctx context.Context) {
+	if !q.config.ShouldSaveDrafts() {
+		return
+	}
+	interval := q.draftInterval()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+
+			var text string
+			fyne.DoAndWait(func() {
+				text = q.textArea.Text
+			})
+			if err := q.saveDraft(text); err != nil {
+				fmt.Printf("Warning: Could not save draft: %v\n", err)
+			}
+		}
+	}()
+}
+
+// closeWindow saves the draft one last time and closes the main window.
+// Window.Close skips the close intercept, so Quit uses this as well.
+func (q *QuickMail) closeWindow() {
+	if q.config.ShouldSaveDrafts() {
+		if err := q.saveDraft(q.textArea.Text); err != nil {
+			fmt.Printf("Warning: Could not save draft: %v\n", err)
+		}
+	}
+	q.window.Close()
+}
+
+// offerDraftRestore asks whether to restore a draft left by an earlier
+// session
+func (q *QuickMail) offerDraftRestore() {
+	path, err := q.draftPath()
+	if err != nil {
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return
+	}
+
+	dialog.ShowConfirm("Restore Draft", "An unsent draft was found. Restore it?", func(restore bool) {
+		if restore {
+			q.textArea.SetText(string(data))
+			q.draftMu.Lock()
+			q.lastDraft = string(data)
+			q.draftMu.Unlock()
+		} else {
+			q.deleteDraft(q.textArea.Text)
+		}
+	}, q.window)
+}
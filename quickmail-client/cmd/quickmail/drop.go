@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+)
+
+// Errors returned by readTextFile for files beyond the limit and for files
+// that are not UTF-8 text
+var (
+	errTooLarge = errors.New("file is too large")
+	errNotUTF8  = errors.New("file is not UTF-8 text")
+)
+
+// readTextFile reads the UTF-8 text file at uri. With a limit above 0,
+// larger files return errTooLarge instead of being truncated.
+func readTextFile(uri fyne.URI, limit int) (string, error) {
+	reader, err := storage.Reader(uri)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	var r io.Reader = reader
+	if limit > 0 {
+		r = io.LimitReader(reader, int64(limit)+1)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	if limit > 0 && len(data) > limit {
+		return "", errTooLarge
+	}
+	if !utf8.Valid(data) {
+		return "", errNotUTF8
+	}
+	return string(data), nil
+}
+
+// loadDroppedFile replaces the message with the first file dropped on the
+// window, such as a .txt or .eml message
+func (q *QuickMail) loadDroppedFile(_ fyne.Position, uris []fyne.URI) {
+	if len(uris) == 0 {
+		return
+	}
+	q.loadTextFile(uris[0])
+}
+
+// maxTextFileSize limits the text files loaded into the message when
+// max_message_bytes is not set
+const maxTextFileSize = 32 << 20
+
+// loadTextFile replaces the message with the text file at uri
+func (q *QuickMail) loadTextFile(uri fyne.URI) {
+	limit := 0
+	if q.config != nil {
+		limit = q.config.MaxMessageBytes
+	}
+	if limit <= 0 {
+		limit = maxTextFileSize
+	}
+	text, err := readTextFile(uri, limit)
+	if errors.Is(err, errTooLarge) {
+		q.showError(fmt.Sprintf("%s is larger than the limit of %d bytes", uri.Name(), limit))
+		return
+	}
+	if errors.Is(err, errNotUTF8) {
+		q.showError(fmt.Sprintf("%s is not UTF-8 text. Convert it to UTF-8 in your editor and open it again.", uri.Name()))
+		return
+	}
+	if err != nil {
+		q.showError(fmt.Sprintf("Could not load %s: %v", uri.Name(), err))
+		return
+	}
+
+	q.replaceMessage(text, uri.Name())
+}
+
+// replaceMessage sets the message text to text loaded from the named
+// file, asking first if the message being composed is not empty
+func (q *QuickMail) replaceMessage(text, name string) {
+	load := func() {
+		q.textArea.SetText(text)
+		q.tabs.SelectIndex(0)
+		q.window.Canvas().Focus(q.textArea)
+	}
+
+	if strings.TrimSpace(q.textArea.Text) == "" {
+		load()
+		return
+	}
+	dialog.ShowConfirm("Replace Message?",
+		fmt.Sprintf("The message being composed will be replaced by %s.\nContinue?", name),
+		func(replace bool) {
+			if replace {
+				load()
+			}
+		}, q.window)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+)
+
+// newUUID returns a random version 4 UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// buildEML wraps the composed text in a minimal RFC 5322 message with CRLF
+// line endings. Headers at the top of the text are kept; Date, Message-ID
+// and a UTF-8 plain text Content-Type are added where missing. Text
+// without a header block becomes the body.
+func buildEML(body string) []byte {
+	text := strings.ReplaceAll(body, "\r\n", "\n")
+
+	var headers []string
+	if h, b, ok := core.SplitMessage(text); ok {
+		if msg, err := mail.ReadMessage(strings.NewReader(h + "\n\n")); err == nil {
+			headers = strings.Split(h, "\n")
+			text = b
+
+			if msg.Header.Get("Date") == "" {
+				headers = append(headers, "Date: "+time.Now().Format(time.RFC1123Z))
+			}
+			if msg.Header.Get("Message-ID") == "" {
+				headers = append(headers, "Message-ID: <"+newUUID()+"@quickmail>")
+			}
+			if msg.Header.Get("Content-Type") == "" {
+				headers = append(headers,
+					"MIME-Version: 1.0",
+					"Content-Type: text/plain; charset=utf-8",
+					"Content-Transfer-Encoding: 8bit")
+			}
+		}
+	}
+	if headers == nil {
+		headers = []string{
+			"Date: " + time.Now().Format(time.RFC1123Z),
+			"Message-ID: <" + newUUID() + "@quickmail>",
+			"MIME-Version: 1.0",
+			"Content-Type: text/plain; charset=utf-8",
+			"Content-Transfer-Encoding: 8bit",
+		}
+	}
+
+	message := strings.Join(headers, "\n") + "\n\n" + text
+	return []byte(strings.ReplaceAll(message, "\n", "\r\n"))
+}
+
+// showExportEMLDialog saves the message being composed as an .eml file
+func (q *QuickMail) showExportEMLDialog() {
+	if strings.TrimSpace(q.textArea.Text) == "" {
+		q.showError("Message is empty")
+		return
+	}
+	data := buildEML(q.textArea.Text)
+
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			q.showError(fmt.Sprintf("Export error: %v", err))
+			return
+		}
+		if writer == nil {
+			return
+		}
+
+		_, err = writer.Write(data)
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			q.showError(fmt.Sprintf("Export error: %v", err))
+			return
+		}
+		q.setStatus("Exported to " + writer.URI().Path())
+	}, q.window)
+	saveDialog.SetFileName("message.eml")
+	saveDialog.SetFilter(storage.NewExtensionFileFilter([]string{".eml"}))
+	saveDialog.Show()
+}
+
+// maxEMLSize limits the .eml files Import .eml reads
+const maxEMLSize = 32 << 20
+
+// parseEMLBody reads a message and returns its plain text body, decoded
+// from quoted-printable or base64. In a multipart message the first
+// text/plain part is used.
+func parseEMLBody(r io.Reader) (string, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return "", fmt.Errorf("not an email message: %w", err)
+	}
+
+	body, err := plainTextPart(textproto.MIMEHeader(msg.Header), msg.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(body, "\r\n", "\n"), nil
+}
+
+// plainTextPart returns the decoded text of a part with the given header,
+// descending into multipart parts
+func plainTextPart(header textproto.MIMEHeader, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return "", errors.New("message has no plain text part")
+			}
+			if err != nil {
+				return "", fmt.Errorf("invalid multipart message: %w", err)
+			}
+			text, err := plainTextPart(part.Header, part)
+			if err == nil {
+				return text, nil
+			}
+		}
+	}
+	if mediaType != "text/plain" {
+		return "", fmt.Errorf("message has no plain text part (%s)", mediaType)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("could not decode message body: %w", err)
+	}
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("message body is not UTF-8 (charset %s)", params["charset"])
+	}
+	return string(data), nil
+}
+
+// showImportEMLDialog replaces the message with the body of an .eml file
+func (q *QuickMail) showImportEMLDialog() {
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			q.showError(fmt.Sprintf("Import error: %v", err))
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		body, err := parseEMLBody(io.LimitReader(reader, maxEMLSize))
+		if err != nil {
+			q.showError(fmt.Sprintf("Import error: %v", err))
+			return
+		}
+		q.replaceMessage(body, reader.URI().Name())
+	}, q.window)
+	openDialog.SetFilter(storage.NewExtensionFileFilter([]string{".eml"}))
+	openDialog.Show()
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestBuildEML(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		// headers that must have exactly these values
+		headers map[string]string
+		// headers that must appear exactly once, with any value
+		once []string
+		body string
+	}{
+		{
+			name:    "headers are kept and completed",
+			text:    "To: alice@example.org\nSubject: Hi\n\nHello\n",
+			headers: map[string]string{"To": "alice@example.org", "Subject": "Hi", "Content-Type": "text/plain; charset=utf-8", "Content-Transfer-Encoding": "8bit", "MIME-Version": "1.0"},
+			once:    []string{"Date", "Message-ID"},
+			body:    "Hello\r\n",
+		},
+		{
+			name: "existing headers are not duplicated",
+			text: "Date: Mon, 02 Mar 2026 10:00:00 +0100\nMessage-ID: <1@example.org>\nContent-Type: text/plain; charset=iso-8859-1\n\nbody",
+			headers: map[string]string{
+				"Date":         "Mon, 02 Mar 2026 10:00:00 +0100",
+				"Message-ID":   "<1@example.org>",
+				"Content-Type": "text/plain; charset=iso-8859-1",
+				"MIME-Version": "",
+			},
+			once: []string{"Date", "Message-ID", "Content-Type"},
+			body: "body",
+		},
+		{
+			name:    "text without headers becomes the body",
+			text:    "Just a note.\nSecond line\n",
+			headers: map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+			once:    []string{"Date", "Message-ID"},
+			body:    "Just a note.\r\nSecond line\r\n",
+		},
+		{
+			name:    "crlf input",
+			text:    "Subject: Hi\r\n\r\nline one\r\nline two",
+			headers: map[string]string{"Subject": "Hi"},
+			once:    []string{"Date", "Message-ID"},
+			body:    "line one\r\nline two",
+		},
+		{
+			name:    "empty text",
+			text:    "",
+			headers: map[string]string{"MIME-Version": "1.0"},
+			once:    []string{"Date", "Message-ID"},
+			body:    "",
+		},
+	}
+
+	messageID := regexp.MustCompile(`^<[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}@quickmail>$`)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eml := buildEML(tt.text)
+
+			if bytes.Contains(bytes.ReplaceAll(eml, []byte("\r\n"), nil), []byte("\n")) {
+				t.Errorf("output has bare LF line endings: %q", eml)
+			}
+
+			msg, err := mail.ReadMessage(bytes.NewReader(eml))
+			if err != nil {
+				t.Fatalf("output is not a message: %v\n%s", err, eml)
+			}
+			for name, want := range tt.headers {
+				if got := msg.Header.Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			for _, name := range tt.once {
+				if values := msg.Header[textproto.CanonicalMIMEHeaderKey(name)]; len(values) != 1 {
+					t.Errorf("%s appears %d times, want once", name, len(values))
+				}
+			}
+			if _, err := msg.Header.Date(); err != nil {
+				t.Errorf("Date: %v", err)
+			}
+			if id := msg.Header.Get("Message-ID"); !strings.HasSuffix(id, "@example.org>") && !messageID.MatchString(id) {
+				t.Errorf("Message-ID = %q, want a UUID at quickmail", id)
+			}
+
+			body, _ := io.ReadAll(msg.Body)
+			if string(body) != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestParseEMLBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		eml     string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "plain",
+			eml:  "Subject: Hi\r\n\r\nHello\r\nWorld\r\n",
+			want: "Hello\nWorld\n",
+		},
+		{
+			name: "no content type is plain text",
+			eml:  "From: a@example.org\n\nbody\n",
+			want: "body\n",
+		},
+		{
+			name: "quoted-printable",
+			eml:  "Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nGr=C3=BC=C3=9Fe, this line is soft=\r\n wrapped\r\n",
+			want: "Grüße, this line is soft wrapped\n",
+		},
+		{
+			name: "base64",
+			eml:  "Content-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: Base64\r\n\r\nR3LDvMOfZSBhdXMg\r\nS8O2bG4K\r\n",
+			want: "Grüße aus Köln\n",
+		},
+		{
+			name: "first plain text part of multipart",
+			eml: "Content-Type: multipart/alternative; boundary=b\r\n\r\n" +
+				"--b\r\nContent-Type: text/html\r\n\r\n<p>html</p>\r\n" +
+				"--b\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nplain =E2=82=AC\r\n" +
+				"--b\r\nContent-Type: text/plain\r\n\r\nsecond\r\n" +
+				"--b--\r\n",
+			want: "plain €",
+		},
+		{
+			name: "nested multipart",
+			eml: "Content-Type: multipart/mixed; boundary=outer\r\n\r\n" +
+				"--outer\r\nContent-Type: multipart/alternative; boundary=inner\r\n\r\n" +
+				"--inner\r\nContent-Type: text/plain\r\nContent-Transfer-Encoding: base64\r\n\r\naW5uZXI=\r\n" +
+				"--inner--\r\n" +
+				"--outer\r\nContent-Type: application/pdf\r\n\r\n%PDF\r\n" +
+				"--outer--\r\n",
+			want: "inner",
+		},
+		{
+			name:    "html only",
+			eml:     "Content-Type: text/html\r\n\r\n<p>hi</p>\r\n",
+			wantErr: "no plain text part (text/html)",
+		},
+		{
+			name:    "multipart without plain text",
+			eml:     "Content-Type: multipart/mixed; boundary=b\r\n\r\n--b\r\nContent-Type: image/png\r\n\r\nPNG\r\n--b--\r\n",
+			wantErr: "no plain text part",
+		},
+		{
+			name:    "not utf-8",
+			eml:     "Content-Type: text/plain; charset=iso-8859-1\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nGr=FC=DFe\r\n",
+			wantErr: "not UTF-8 (charset iso-8859-1)",
+		},
+		{
+			name:    "broken base64",
+			eml:     "Content-Transfer-Encoding: base64\r\n\r\n!!!\r\n",
+			wantErr: "could not decode",
+		},
+		{
+			name:    "not a message",
+			eml:     "this is not an email",
+			wantErr: "not an email message",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEMLBody(strings.NewReader(tt.eml))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseEMLBody error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseEMLBody: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseEMLBody = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEMLRoundTrip(t *testing.T) {
+	const body = "Grüße,\nthis is the message.\n\n-- \nQuickMail\n"
+	for _, text := range []string{body, "To: alice@example.org\nSubject: Hi\n\n" + body} {
+		got, err := parseEMLBody(bytes.NewReader(buildEML(text)))
+		if err != nil {
+			t.Fatalf("parseEMLBody: %v", err)
+		}
+		if got != body {
+			t.Errorf("imported %q, want %q", got, body)
+		}
+	}
+}
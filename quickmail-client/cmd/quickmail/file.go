@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+)
+
+// textFileFilter limits the Open and Save dialogs to message text files
+var textFileFilter = storage.NewExtensionFileFilter([]string{".txt", ".eml"})
+
+// showOpenFileDialog replaces the message with a text file, for example
+// one written in an external editor
+func (q *QuickMail) showOpenFileDialog() {
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			q.showError(fmt.Sprintf("Open error: %v", err))
+			return
+		}
+		if reader == nil {
+			return
+		}
+		reader.Close()
+
+		q.loadTextFile(reader.URI())
+	}, q.window)
+	openDialog.SetFilter(textFileFilter)
+	openDialog.Show()
+}
+
+// showSaveFileDialog writes the message to a text file named after the
+// current time by default. The text is written byte for byte, so encoded
+// headers such as a MIME subject are kept exactly as inserted.
+func (q *QuickMail) showSaveFileDialog() {
+	text := q.textArea.Text
+
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			q.showError(fmt.Sprintf("Save error: %v", err))
+			return
+		}
+		if writer == nil {
+			return
+		}
+
+		_, err = writer.Write([]byte(text))
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			q.showError(fmt.Sprintf("Save error: %v", err))
+			return
+		}
+		q.setStatus("Saved to " + writer.URI().Path())
+	}, q.window)
+	saveDialog.SetFileName(time.Now().Format("2006-01-02_150405") + ".txt")
+	saveDialog.SetFilter(textFileFilter)
+	saveDialog.Show()
+}
+
+// copyText copies the text selected in the message entry to the clipboard
+func (q *QuickMail) copyText() {
+	q.textArea.TypedShortcut(&fyne.ShortcutCopy{Clipboard: q.window.Clipboard()})
+}
+
+// pasteText inserts the clipboard content at the cursor of the message
+// entry
+func (q *QuickMail) pasteText() {
+	q.window.Canvas().Focus(q.textArea)
+	q.textArea.TypedShortcut(&fyne.ShortcutPaste{Clipboard: q.window.Clipboard()})
+}
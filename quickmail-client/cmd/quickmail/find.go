@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Fyne's Entry cannot select text programmatically, so the current match
+// is highlighted by wrapping it in findMarkOpen and findMarkClose. The
+// markers are removed again before the next search, when the find window
+// closes and before a message is sent.
+const (
+	findMarkOpen  = "[["
+	findMarkClose = "]]"
+)
+
+// findMark is the marked match in the text area as byte offsets,
+// including the markers
+type findMark struct {
+	start, end int
+	active     bool
+}
+
+// replaceAll returns text with every occurrence of find replaced; an
+// empty find leaves text unchanged
+func replaceAll(text, find, replace string) string {
+	if find == "" {
+		return text
+	}
+	return strings.ReplaceAll(text, find, replace)
+}
+
+// findNext returns the byte offset of the next occurrence of find at or
+// after from, wrapping around to the start of text, or -1 if there is none
+func findNext(text, find string, from int) int {
+	if find == "" {
+		return -1
+	}
+	if from < 0 || from > len(text) {
+		from = 0
+	}
+	if i := strings.Index(text[from:], find); i >= 0 {
+		return from + i
+	}
+	return strings.Index(text, find)
+}
+
+// clearFindMark removes the match markers from the text area, unless the
+// text was edited so they are no longer where they were put
+func (q *QuickMail) clearFindMark() {
+	mark := q.findMark
+	q.findMark = findMark{}
+	if !mark.active {
+		return
+	}
+
+	text := q.textArea.Text
+	if mark.end > len(text) || !strings.HasPrefix(text[mark.start:], findMarkOpen) ||
+		!strings.HasSuffix(text[:mark.end], findMarkClose) {
+		return
+	}
+	q.textArea.SetText(text[:mark.start] + text[mark.start+len(findMarkOpen):mark.end-len(findMarkClose)] + text[mark.end:])
+}
+
+// markNext marks the next occurrence of find after the previous match
+func (q *QuickMail) markNext(find string) {
+	q.clearFindMark()
+	text := q.textArea.Text
+
+	pos := findNext(text, find, q.findPos)
+	if pos < 0 {
+		q.setStatus(fmt.Sprintf("%q not found", find))
+		return
+	}
+
+	end := pos + len(find)
+	q.textArea.SetText(text[:pos] + findMarkOpen + text[pos:end] + findMarkClose + text[end:])
+	q.findMark = findMark{start: pos, end: end + len(findMarkOpen) + len(findMarkClose), active: true}
+	q.findPos = end
+
+	q.textArea.CursorRow, q.textArea.CursorColumn = cursorPosition(q.textArea.Text, q.findMark.end)
+	q.textArea.Refresh()
+	q.setStatus("")
+}
+
+// replaceMarked replaces the marked match and marks the next one
+func (q *QuickMail) replaceMarked(find, replace string) {
+	mark := q.findMark
+	text := q.textArea.Text
+	if mark.active && mark.end <= len(text) && text[mark.start:mark.end] == findMarkOpen+find+findMarkClose {
+		q.findMark = findMark{}
+		q.textArea.SetText(text[:mark.start] + replace + text[mark.end:])
+		q.findPos = mark.start + len(replace)
+	}
+	q.markNext(find)
+}
+
+// showFindReplaceDialog opens the find and replace window, which stays
+// open next to the main window while the message is edited
+func (q *QuickMail) showFindReplaceDialog() {
+	if q.findWindow != nil {
+		q.findWindow.RequestFocus()
+		return
+	}
+
+	findEntry := widget.NewEntry()
+	findEntry.PlaceHolder = "Find"
+	replaceEntry := widget.NewEntry()
+	replaceEntry.PlaceHolder = "Replace with"
+
+	nextButton := widget.NewButton("Next", func() {
+		q.markNext(findEntry.Text)
+	})
+	replaceButton := widget.NewButton("Replace", func() {
+		q.replaceMarked(findEntry.Text, replaceEntry.Text)
+	})
+	replaceAllButton := widget.NewButton("Replace All", func() {
+		q.clearFindMark()
+		count := 0
+		if findEntry.Text != "" {
+			count = strings.Count(q.textArea.Text, findEntry.Text)
+		}
+		q.textArea.SetText(replaceAll(q.textArea.Text, findEntry.Text, replaceEntry.Text))
+		q.findPos = 0
+		q.setStatus(fmt.Sprintf("Replaced %d occurrences", count))
+	})
+
+	window := q.app.NewWindow("Find and Replace")
+	window.SetContent(container.NewVBox(
+		widget.NewForm(
+			widget.NewFormItem("Find:", findEntry),
+			widget.NewFormItem("Replace:", replaceEntry),
+		),
+		container.NewHBox(nextButton, replaceButton, replaceAllButton),
+	))
+	window.SetOnClosed(func() {
+		q.clearFindMark()
+		q.findWindow = nil
+	})
+	window.Resize(fyne.NewSize(400, 150))
+
+	q.findWindow = window
+	q.findPos = 0
+	window.Show()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+
+	"fyne.io/fyne/v2/test"
+)
+
+func TestReplaceAll(t *testing.T) {
+	tests := []struct {
+		name, text, find, replace, want string
+	}{
+		{"every occurrence", "foo bar foo", "foo", "baz", "baz bar baz"},
+		{"empty find leaves text", "foo", "", "x", "foo"},
+		{"no match", "foo", "qux", "x", "foo"},
+		{"delete", "a-b-c", "-", "", "abc"},
+		{"case-sensitive", "Foo foo", "foo", "bar", "Foo bar"},
+		{"replacement contains find", "aa", "a", "aa", "aaaa"},
+		{"non-overlapping", "aaa", "aa", "b", "ba"},
+		{"multi-byte", "Grüße, Grüße", "ü", "ue", "Grueße, Grueße"},
+		{"across lines", "line\nline", "\n", " ", "line line"},
+		{"empty text", "", "a", "b", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replaceAll(tt.text, tt.find, tt.replace); got != tt.want {
+				t.Errorf("replaceAll(%q, %q, %q) = %q, want %q", tt.text, tt.find, tt.replace, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindNext(t *testing.T) {
+	const text = "one two one two"
+
+	tests := []struct {
+		name string
+		find string
+		from int
+		want int
+	}{
+		{"first", "two", 0, 4},
+		{"at from", "two", 4, 4},
+		{"after from", "two", 5, 12},
+		{"wraps around", "one", 9, 0},
+		{"from at end wraps", "one", len(text), 0},
+		{"from out of range starts over", "two", 99, 4},
+		{"negative from starts over", "two", -1, 4},
+		{"not found", "three", 0, -1},
+		{"empty find", "", 0, -1},
+	}
+
+	for _, tt := range tests {
+		if got := findNext(text, tt.find, tt.from); got != tt.want {
+			t.Errorf("%s: findNext(%q, %d) = %d, want %d", tt.name, tt.find, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestReplaceMarked(t *testing.T) {
+	test.NewTempApp(t)
+	q := &QuickMail{textArea: newMessageEntry()}
+	q.textArea.SetText("cat, cat and cat")
+
+	q.markNext("cat")
+	if want := "[[cat]], cat and cat"; q.textArea.Text != want {
+		t.Fatalf("after markNext text = %q, want %q", q.textArea.Text, want)
+	}
+
+	q.replaceMarked("cat", "dog")
+	if want := "dog, [[cat]] and cat"; q.textArea.Text != want {
+		t.Fatalf("after replaceMarked text = %q, want %q", q.textArea.Text, want)
+	}
+
+	q.markNext("cat")
+	q.clearFindMark()
+	if want := "dog, cat and cat"; q.textArea.Text != want {
+		t.Errorf("after clearFindMark text = %q, want %q", q.textArea.Text, want)
+	}
+
+	// Edits that remove the markers must not be undone by clearing
+	q.markNext("and")
+	q.textArea.SetText("rewritten")
+	q.clearFindMark()
+	if q.textArea.Text != "rewritten" {
+		t.Errorf("clearFindMark changed edited text to %q", q.textArea.Text)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const (
+	// defaultFontSize is the text size of the message entry
+	defaultFontSize float32 = 14
+	// fontSizeStep is how much Ctrl+= and Ctrl+- change the size
+	fontSizeStep float32 = 2
+	minFontSize  float32 = 8
+	maxFontSize  float32 = 40
+)
+
+// fontSizeTheme is the current app theme with the text size replaced by
+// the font size of the message entry
+type fontSizeTheme struct {
+	q *QuickMail
+}
+
+func (t fontSizeTheme) base() fyne.Theme {
+	return t.q.app.Settings().Theme()
+}
+
+func (t fontSizeTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) color.Color {
+	return t.base().Color(name, variant)
+}
+
+func (t fontSizeTheme) Font(style fyne.TextStyle) fyne.Resource {
+	return t.base().Font(style)
+}
+
+func (t fontSizeTheme) Icon(name fyne.ThemeIconName) fyne.Resource {
+	return t.base().Icon(name)
+}
+
+func (t fontSizeTheme) Size(name fyne.ThemeSizeName) float32 {
+	if name == theme.SizeNameText {
+		return t.q.fontSize
+	}
+	return t.base().Size(name)
+}
+
+// newTextAreaOverride wraps the message entry so its font size can be
+// changed without affecting the rest of the window
+func (q *QuickMail) newTextAreaOverride(content fyne.CanvasObject) fyne.CanvasObject {
+	q.textOverride = container.NewThemeOverride(content, fontSizeTheme{q})
+	return q.textOverride
+}
+
+// configFontSize returns the font size saved in the config, or
+// defaultFontSize
+func configFontSize(config *core.Config) float32 {
+	if config == nil || config.FontSize == 0 {
+		return defaultFontSize
+	}
+	return min(max(config.FontSize, minFontSize), maxFontSize)
+}
+
+// setFontSize changes the font size of the message entry within
+// minFontSize and maxFontSize and saves it to the config
+func (q *QuickMail) setFontSize(size float32) {
+	size = min(max(size, minFontSize), maxFontSize)
+	if size == q.fontSize {
+		return
+	}
+
+	q.fontSize = size
+	if q.textOverride != nil {
+		q.textOverride.Refresh()
+	}
+
+	if q.config != nil {
+		q.config.FontSize = size
+		if err := core.SaveConfig(q.config); err != nil {
+			fmt.Printf("Warning: Could not save config: %v\n", err)
+		}
+	}
+}
+
+func (q *QuickMail) increaseFontSize() {
+	q.setFontSize(q.fontSize + fontSizeStep)
+}
+
+func (q *QuickMail) decreaseFontSize() {
+	q.setFontSize(q.fontSize - fontSizeStep)
+}
+
+func (q *QuickMail) resetFontSize() {
+	q.setFontSize(defaultFontSize)
+}
+
+// monospaceKey is the preference holding whether the message entry uses
+// the monospace font
+const monospaceKey = "monospace"
+
+// newMonospaceButton creates the top bar button switching the message
+// entry between the monospace and the proportional font, which is
+// highlighted while the monospace font is used
+func (q *QuickMail) newMonospaceButton() *tipButton {
+	button := newTipButton("Mono", nil, q.shortcutTip("Toggle Monospace"), q.tooltips, q.toggleMonospace)
+	q.monospaceButton = &button.Button
+	q.applyMonospace(q.app.Preferences().BoolWithFallback(monospaceKey, true))
+	return button
+}
+
+// applyMonospace sets the font of the message entry; other widgets keep
+// the theme font
+func (q *QuickMail) applyMonospace(monospace bool) {
+	q.textArea.TextStyle.Monospace = monospace
+	q.textArea.Refresh()
+	q.updateLineNumbers()
+
+	if q.monospaceButton != nil {
+		q.monospaceButton.Importance = widget.LowImportance
+		if monospace {
+			q.monospaceButton.Importance = widget.MediumImportance
+		}
+		q.monospaceButton.Refresh()
+	}
+}
+
+// toggleMonospace switches the font of the message entry and remembers the
+// choice for the next start
+func (q *QuickMail) toggleMonospace() {
+	monospace := !q.textArea.TextStyle.Monospace
+	q.applyMonospace(monospace)
+	q.app.Preferences().SetBool(monospaceKey, monospace)
+}
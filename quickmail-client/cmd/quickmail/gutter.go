@@ -0,0 +1,226 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// lineNumbersKey is the preference holding whether line numbers are shown
+const lineNumbersKey = "lineNumbers"
+
+// lineNumberWidget draws the line numbers of the message entry in a column
+// to its left. It is not part of the scrolled content, so it stays in place
+// when long lines are scrolled sideways, and follows the vertical offset of
+// the editor instead.
+type lineNumberWidget struct {
+	widget.BaseWidget
+
+	lines  int
+	offset float32
+
+	// style is the text style of the entry, which sets the row height
+	style fyne.TextStyle
+}
+
+// newLineNumberWidget creates a gutter for a text of one line
+func newLineNumberWidget() *lineNumberWidget {
+	w := &lineNumberWidget{lines: 1}
+	w.ExtendBaseWidget(w)
+	return w
+}
+
+// setLines sets the number of lines of the text
+func (w *lineNumberWidget) setLines(lines int) {
+	if lines == w.lines {
+		return
+	}
+	w.lines = lines
+	w.Refresh()
+}
+
+// setOffset sets the vertical scroll offset of the text
+func (w *lineNumberWidget) setOffset(offset float32) {
+	if offset == w.offset {
+		return
+	}
+	w.offset = offset
+	w.Refresh()
+}
+
+// rowMetrics returns the height of a text row in style and the padding
+// above the first row, matching the layout of a multi-line entry that does
+// not scroll itself
+func rowMetrics(th fyne.Theme, style fyne.TextStyle) (rowHeight, padding float32) {
+	size := th.Size(theme.SizeNameText)
+	rowHeight = fyne.MeasureText("0", size, style).Height
+	return rowHeight, th.Size(theme.SizeNameInnerPadding)
+}
+
+func (w *lineNumberWidget) CreateRenderer() fyne.WidgetRenderer {
+	return &lineNumberRenderer{w: w}
+}
+
+// lineNumberRenderer keeps one canvas.Text per visible row
+type lineNumberRenderer struct {
+	w     *lineNumberWidget
+	texts []*canvas.Text
+}
+
+func (r *lineNumberRenderer) MinSize() fyne.Size {
+	th := r.w.Theme()
+	digits := strings.Repeat("0", len(strconv.Itoa(r.w.lines)))
+	width := fyne.MeasureText(digits, th.Size(theme.SizeNameText), fyne.TextStyle{Monospace: true}).Width
+	return fyne.NewSize(width+th.Size(theme.SizeNameInnerPadding)*2, 0)
+}
+
+func (r *lineNumberRenderer) Layout(size fyne.Size) {
+	th := r.w.Theme()
+	rowHeight, padding := rowMetrics(th, r.w.style)
+	textColor := th.Color(theme.ColorNamePlaceHolder, fyne.CurrentApp().Settings().ThemeVariant())
+
+	first := max(int((r.w.offset-padding)/rowHeight), 0)
+	visible := int(size.Height/rowHeight) + 2
+	for len(r.texts) < visible {
+		text := canvas.NewText("", textColor)
+		text.TextStyle = fyne.TextStyle{Monospace: true}
+		text.Alignment = fyne.TextAlignTrailing
+		r.texts = append(r.texts, text)
+	}
+
+	for i, text := range r.texts {
+		line := first + i
+		if i >= visible || line >= r.w.lines {
+			text.Hide()
+			continue
+		}
+		text.Text = strconv.Itoa(line + 1)
+		text.TextSize = th.Size(theme.SizeNameText)
+		text.Color = textColor
+		text.Resize(fyne.NewSize(size.Width-padding, rowHeight))
+		text.Move(fyne.NewPos(0, padding+float32(line)*rowHeight-r.w.offset))
+		text.Show()
+		text.Refresh()
+	}
+}
+
+func (r *lineNumberRenderer) Refresh() {
+	r.Layout(r.w.Size())
+	canvas.Refresh(r.w)
+}
+
+func (r *lineNumberRenderer) Objects() []fyne.CanvasObject {
+	objects := make([]fyne.CanvasObject, len(r.texts))
+	for i, text := range r.texts {
+		objects[i] = text
+	}
+	return objects
+}
+
+func (r *lineNumberRenderer) Destroy() {}
+
+// newEditor wraps the message entry in the scroll container used while
+// line numbers are shown, with the gutter to its left
+func (q *QuickMail) newEditor() fyne.CanvasObject {
+	q.lineNumbers = newLineNumberWidget()
+	q.editorScroll = container.NewScroll(q.textArea)
+	q.editorScroll.OnScrolled = func(offset fyne.Position) {
+		q.lineNumbers.setOffset(offset.Y)
+	}
+	q.editor = container.NewBorder(nil, nil, q.lineNumbers, nil, q.editorScroll)
+	q.showLineNumbers = q.app.Preferences().BoolWithFallback(lineNumbersKey, false)
+	q.updateLineNumbers()
+	return q.editor
+}
+
+// lineNumbersShown reports whether the gutter is shown. Fyne does not
+// expose where the entry wraps lines, so numbers are only shown while
+// word wrap is off.
+func (q *QuickMail) lineNumbersShown() bool {
+	return q.showLineNumbers && q.textArea.Wrapping == fyne.TextWrapOff
+}
+
+// updateLineNumbers shows or hides the gutter and matches it to the font of
+// the entry. While it is shown the entry does not scroll itself but grows
+// with its text inside editorScroll, whose offset the gutter can follow.
+func (q *QuickMail) updateLineNumbers() {
+	if q.lineNumbers == nil {
+		return
+	}
+
+	if q.lineNumbersShown() {
+		q.textArea.Scroll = fyne.ScrollNone
+		q.lineNumbers.style = q.textArea.TextStyle
+		q.lineNumbers.setLines(strings.Count(q.textArea.Text, "\n") + 1)
+		q.lineNumbers.Show()
+		q.lineNumbers.Refresh()
+	} else {
+		q.textArea.Scroll = fyne.ScrollBoth
+		q.lineNumbers.Hide()
+		q.scrollEditorTo(fyne.Position{})
+	}
+	q.textArea.Refresh()
+	q.editor.Refresh()
+}
+
+// textChangedLineNumbers updates the gutter after the text changed
+func (q *QuickMail) textChangedLineNumbers(text string) {
+	if q.lineNumbers != nil && q.lineNumbersShown() {
+		q.lineNumbers.setLines(strings.Count(text, "\n") + 1)
+	}
+}
+
+// scrollToCursor keeps the cursor visible while the entry does not scroll
+// itself
+func (q *QuickMail) scrollToCursor() {
+	if q.lineNumbers == nil || !q.lineNumbersShown() {
+		return
+	}
+
+	th := q.lineNumbers.Theme()
+	rowHeight, padding := rowMetrics(th, q.textArea.TextStyle)
+	lines := strings.Split(q.textArea.Text, "\n")
+	row := min(q.textArea.CursorRow, len(lines)-1)
+	line := []rune(lines[row])
+	col := min(q.textArea.CursorColumn, len(line))
+	x := padding + fyne.MeasureText(string(line[:col]), th.Size(theme.SizeNameText), q.textArea.TextStyle).Width
+	y := padding + float32(row)*rowHeight
+
+	view := q.editorScroll.Size()
+	offset := q.editorScroll.Offset
+	if y < offset.Y {
+		offset.Y = y - padding
+	} else if y+rowHeight+padding > offset.Y+view.Height {
+		offset.Y = y + rowHeight + padding - view.Height
+	}
+	if x < offset.X+padding {
+		offset.X = x - padding
+	} else if x+padding > offset.X+view.Width {
+		offset.X = x + padding - view.Width
+	}
+	q.scrollEditorTo(offset)
+}
+
+// scrollEditorTo scrolls the editor to offset. ScrollToOffset does not
+// call OnScrolled, so the gutter is moved along here.
+func (q *QuickMail) scrollEditorTo(offset fyne.Position) {
+	q.editorScroll.ScrollToOffset(offset)
+	q.lineNumbers.setOffset(q.editorScroll.Offset.Y)
+}
+
+// toggleLineNumbers shows or hides the line numbers and remembers the
+// choice for the next start. Showing them turns word wrap off.
+func (q *QuickMail) toggleLineNumbers() {
+	q.showLineNumbers = !q.showLineNumbers
+	q.app.Preferences().SetBool(lineNumbersKey, q.showLineNumbers)
+	if q.showLineNumbers && q.textArea.Wrapping != fyne.TextWrapOff {
+		q.toggleWordWrap()
+		return
+	}
+	q.updateLineNumbers()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// pingPath is the server endpoint answering 200 OK when the server is up
+const pingPath = "/ping"
+
+// checkServerHealth sends a GET to the ping endpoint of the server at
+// serverURL through Tor, with the same transport and timeout as
+// uploadMessage, and returns an error unless it answers 200 OK
+func (q *QuickMail) checkServerHealth(serverURL string) error {
+	client, err := q.torClient()
+	if err != nil {
+		return err
+	}
+
+	_, err = getBody(context.Background(), client, serverURL+pingPath, 1024)
+	return err
+}
+
+// testConnection checks in the background whether the active server is
+// reachable and reports the result in a dialog
+func (q *QuickMail) testConnection() {
+	server := q.activeServer()
+	if server == nil {
+		return
+	}
+	serverURL := server.BaseURL(q.config.UseTLS)
+
+	q.setStatus("Testing connection...")
+	go func() {
+		if err := q.checkServerHealth(serverURL); err != nil {
+			q.setStatus("Connection test failed")
+			q.showError(fmt.Sprintf("Server %s is not reachable: %v", server.Name, err))
+			return
+		}
+		q.setStatus("Connection test passed")
+		q.showSuccess(fmt.Sprintf("Server %s is reachable", server.Name))
+	}()
+}
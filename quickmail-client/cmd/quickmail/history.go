@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// HistoryEntry records a sent message in history.jsonl. The message body
+// is never logged.
+type HistoryEntry struct {
+	Sent    time.Time `json:"sent"`
+	Subject string    `json:"subject,omitempty"`
+	Bytes   int       `json:"bytes"`
+	Elapsed string    `json:"elapsed"`
+	Server  string    `json:"server"`
+
+	// DurationMs is the upload time in milliseconds, for scripts reading
+	// the log; Elapsed holds the same time for display
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// historyPath returns the location of history.jsonl
+func (q *QuickMail) historyPath() (string, error) {
+	dir, err := core.DataDir(q.config)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+// appendHistory adds an entry as one JSON line to the end of the history
+// file at path, creating it if needed
+func appendHistory(path string, entry HistoryEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// recordSent logs a successfully uploaded message to the history
+func (q *QuickMail) recordSent(serverURL string, message []byte, elapsed time.Duration) {
+	// Only the header block is copied out of the locked message
+	headers := message
+	if end := bytes.Index(message, []byte("\n\n")); end >= 0 {
+		headers = message[:end]
+	}
+
+	path, err := q.historyPath()
+	if err == nil {
+		err = appendHistory(path, HistoryEntry{
+			Sent:       time.Now(),
+			Subject:    core.MessageSubject(string(headers)),
+			Bytes:      len(message),
+			Elapsed:    q.formatDuration(elapsed),
+			Server:     serverURL,
+			DurationMs: elapsed.Milliseconds(),
+		})
+	}
+	if err != nil {
+		fmt.Printf("Warning: Could not write history: %v\n", err)
+	}
+}
+
+// loadHistory returns the sent messages, most recent first. Lines that
+// cannot be parsed are skipped.
+func (q *QuickMail) loadHistory() ([]HistoryEntry, error) {
+	path, err := q.historyPath()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// showHistoryDialog lists the sent messages
+func (q *QuickMail) showHistoryDialog() {
+	entries, err := q.loadHistory()
+	if err != nil {
+		q.showError(fmt.Sprintf("History error: %v", err))
+		return
+	}
+
+	list := widget.NewList(
+		func() int {
+			return len(entries)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			entry := entries[id]
+			subject := entry.Subject
+			if subject == "" {
+				subject = "(no subject)"
+			}
+			item.(*widget.Label).SetText(fmt.Sprintf("%s  %s  %d bytes  %s  %s",
+				entry.Sent.Local().Format("2006-01-02 15:04"), subject, entry.Bytes, entry.Elapsed, entry.Server))
+		},
+	)
+
+	historyDialog := dialog.NewCustom("History", "Close", list, q.window)
+	historyDialog.Resize(fyne.NewSize(700, 400))
+	historyDialog.Show()
+}
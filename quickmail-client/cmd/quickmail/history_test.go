@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAppendHistory(t *testing.T) {
+	sent := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		existing string
+		entries  []HistoryEntry
+		want     []string
+	}{
+		{
+			name:    "creates the file",
+			entries: []HistoryEntry{{Sent: sent, Subject: "Hi", Bytes: 42, Elapsed: "00:00:03", Server: "http://a.onion/upload", DurationMs: 3100}},
+			want: []string{
+				`{"sent":"2026-03-01T12:30:00Z","subject":"Hi","bytes":42,"elapsed":"00:00:03","server":"http://a.onion/upload","duration_ms":3100}`,
+			},
+		},
+		{
+			name:     "appends to existing lines",
+			existing: "{\"sent\":\"2026-01-01T00:00:00Z\",\"bytes\":1,\"elapsed\":\"00:00:01\",\"server\":\"x\",\"duration_ms\":1}\n",
+			entries:  []HistoryEntry{{Sent: sent, Bytes: 7, Elapsed: "00:00:00", Server: "x"}},
+			want: []string{
+				`{"sent":"2026-01-01T00:00:00Z","bytes":1,"elapsed":"00:00:01","server":"x","duration_ms":1}`,
+				`{"sent":"2026-03-01T12:30:00Z","bytes":7,"elapsed":"00:00:00","server":"x","duration_ms":0}`,
+			},
+		},
+		{
+			name: "one line per entry",
+			entries: []HistoryEntry{
+				{Sent: sent, Subject: "first\nwith newline", Server: "x"},
+				{Sent: sent, Subject: "Grüße", Server: "x"},
+			},
+			want: []string{
+				`{"sent":"2026-03-01T12:30:00Z","subject":"first\nwith newline","bytes":0,"elapsed":"","server":"x","duration_ms":0}`,
+				`{"sent":"2026-03-01T12:30:00Z","subject":"Grüße","bytes":0,"elapsed":"","server":"x","duration_ms":0}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "history.jsonl")
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, entry := range tt.entries {
+				if err := appendHistory(path, entry); err != nil {
+					t.Fatalf("appendHistory: %v", err)
+				}
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := strings.Join(tt.want, "\n") + "\n"; string(data) != want {
+				t.Errorf("history file:\n%s\nwant:\n%s", data, want)
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.existing == "" && info.Mode().Perm() != 0600 {
+				t.Errorf("history file mode = %v, want 0600", info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestAppendHistoryConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	// Every write goes to the end of the file, so entries appended at the
+	// same time end up as whole lines
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			entry := HistoryEntry{Subject: fmt.Sprintf("message %d %s", i, strings.Repeat("x", 500)), Bytes: i}
+			if err := appendHistory(path, entry); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	seen := make(map[int]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("line %q is not a whole entry: %v", scanner.Text(), err)
+		}
+		seen[entry.Bytes] = true
+	}
+	if len(seen) != n {
+		t.Errorf("found %d distinct entries, want %d", len(seen), n)
+	}
+}
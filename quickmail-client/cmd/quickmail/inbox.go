@@ -0,0 +1,448 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// defaultInboxPath is the server endpoint listing received messages
+const defaultInboxPath = "/messages"
+
+// maxInboxMessageBytes limits the size of a downloaded message
+const maxInboxMessageBytes = 10 << 20
+
+// InboxMessage is one entry of the message list returned by the server,
+// or a message in the local maildir
+type InboxMessage struct {
+	ID   string `json:"id"`
+	Size int64  `json:"size"`
+	Date string `json:"date"`
+
+	// Path is the file in the maildir, Seen reports whether the message
+	// was opened and Remote whether it is still on the server
+	Path   string `json:"-"`
+	Seen   bool   `json:"-"`
+	Remote bool   `json:"-"`
+
+	// received is when the message was stored in the maildir
+	received time.Time
+}
+
+// inboxURL returns the URL of the message list on server
+func (q *QuickMail) inboxURL(server *core.ServerProfile) string {
+	path := defaultInboxPath
+	if q.config != nil && q.config.InboxPath != "" {
+		path = q.config.InboxPath
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return server.BaseURL(q.config.UseTLS) + path
+}
+
+// getBody performs a GET request and returns the complete response body,
+// an error if it is larger than limit, or a core.StatusError if the server
+// does not answer with 200
+func getBody(ctx context.Context, client *http.Client, target string, limit int64) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, &core.StatusError{
+			StatusCode: response.StatusCode,
+			Status:     response.Status,
+			Body:       string(body),
+		}
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("response is larger than %d bytes", limit)
+	}
+	return body, nil
+}
+
+// fetchInbox downloads the message list from the active server
+func (q *QuickMail) fetchInbox(ctx context.Context) ([]InboxMessage, error) {
+	server := q.config.Profile(q.activeProfile)
+	if server == nil {
+		return nil, errors.New("no server profile selected")
+	}
+	if err := server.CheckAddress(); err != nil {
+		return nil, err
+	}
+
+	client, err := q.torClient()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := getBody(ctx, client, q.inboxURL(server), maxInboxMessageBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []InboxMessage
+	if err := json.Unmarshal(body, &messages); err != nil {
+		return nil, fmt.Errorf("could not parse message list: %w", err)
+	}
+	return messages, nil
+}
+
+// fetchInboxMessage downloads the message with the given id from the
+// active server
+func (q *QuickMail) fetchInboxMessage(ctx context.Context, id string) (string, error) {
+	server := q.config.Profile(q.activeProfile)
+	if server == nil {
+		return "", errors.New("no server profile selected")
+	}
+
+	client, err := q.torClient()
+	if err != nil {
+		return "", err
+	}
+
+	body, err := getBody(ctx, client, q.inboxURL(server)+"/"+url.PathEscape(id), maxInboxMessageBytes)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// deleteInboxMessage removes the message with the given id from the active
+// server. The server answers 200 or 204 when the message was deleted.
+func (q *QuickMail) deleteInboxMessage(ctx context.Context, id string) error {
+	server := q.config.Profile(q.activeProfile)
+	if server == nil {
+		return errors.New("no server profile selected")
+	}
+
+	client, err := q.torClient()
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "DELETE", q.inboxURL(server)+"/"+url.PathEscape(id), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		return &core.StatusError{
+			StatusCode: response.StatusCode,
+			Status:     response.Status,
+			Body:       string(body),
+		}
+	}
+	return nil
+}
+
+// maildir returns the local mailbox next to the config, creating it if
+// necessary
+func (q *QuickMail) maildir() (Maildir, error) {
+	dir, err := core.DataDir(q.config)
+	if err != nil {
+		return "", err
+	}
+	return openMaildir(filepath.Join(dir, "Maildir"))
+}
+
+// syncInbox downloads the messages on the active server that are not in
+// the maildir yet and returns the maildir contents along with the number
+// of new messages. With delete_after_download a message is deleted from
+// the server only after it was completely stored.
+func (q *QuickMail) syncInbox(ctx context.Context) ([]InboxMessage, int, error) {
+	q.inboxMu.Lock()
+	defer q.inboxMu.Unlock()
+
+	box, err := q.maildir()
+	if err != nil {
+		return nil, 0, err
+	}
+	local, err := box.List()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	remote, err := q.fetchInbox(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stored := make(map[string]bool, len(local))
+	for _, message := range local {
+		stored[message.ID] = true
+	}
+
+	deleteAfter := q.config.DeleteAfterDownload
+	onServer := make(map[string]bool, len(remote))
+	added := 0
+	for _, message := range remote {
+		onServer[message.ID] = true
+		if stored[message.ID] {
+			continue
+		}
+
+		body, err := q.fetchInboxMessage(ctx, message.ID)
+		if err != nil {
+			return nil, added, err
+		}
+		if _, err := box.Deliver(message.ID, []byte(body)); err != nil {
+			return nil, added, err
+		}
+		added++
+
+		if deleteAfter {
+			if err := q.deleteInboxMessage(ctx, message.ID); err != nil {
+				return nil, added, fmt.Errorf("the message was kept on the server: %w", err)
+			}
+			onServer[message.ID] = false
+		}
+	}
+
+	messages, err := box.List()
+	if err != nil {
+		return nil, added, err
+	}
+	for i := range messages {
+		messages[i].Remote = onServer[messages[i].ID]
+	}
+	return messages, added, nil
+}
+
+// loadInbox fills the message list from the maildir, so messages
+// downloaded earlier can be read without a connection
+func (q *QuickMail) loadInbox() {
+	box, err := q.maildir()
+	if err == nil {
+		q.inbox, err = box.List()
+	}
+	if err != nil {
+		fmt.Printf("Warning: Could not load local messages: %v\n", err)
+	}
+}
+
+// setInbox shows messages in the message list
+func (q *QuickMail) setInbox(messages []InboxMessage) {
+	q.inbox = messages
+	q.inboxList.UnselectAll()
+	q.inboxList.Refresh()
+	q.inboxViewer.SetText("")
+}
+
+// deleteSelectedMessage asks for confirmation and deletes the selected
+// message from the server
+func (q *QuickMail) deleteSelectedMessage() {
+	if q.inboxSelected < 0 || q.inboxSelected >= len(q.inbox) {
+		q.showError("No message selected")
+		return
+	}
+	if !q.inbox[q.inboxSelected].Remote {
+		q.showError("The message is not on the server, or the inbox has not been fetched yet")
+		return
+	}
+	messageID := q.inbox[q.inboxSelected].ID
+
+	dialog.ShowConfirm("Delete from Server",
+		"Delete the selected message from the server? This cannot be undone.\nThe copy in the local mailbox is kept.",
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+
+			q.setStatus("Deleting message...")
+			go func() {
+				if err := q.deleteInboxMessage(context.Background(), messageID); err != nil {
+					q.setStatus("Deleting message failed")
+					q.showError(fmt.Sprintf("Delete error: %v", err))
+					return
+				}
+
+				q.setStatus("Message deleted from the server")
+				fyne.Do(func() {
+					for i := range q.inbox {
+						if q.inbox[i].ID == messageID {
+							q.inbox[i].Remote = false
+						}
+					}
+					q.inboxList.Refresh()
+				})
+			}()
+		},
+		q.window,
+	)
+}
+
+// refreshInbox downloads new messages into the maildir in the background
+func (q *QuickMail) refreshInbox() {
+	if q.config == nil {
+		q.showError("Configuration not loaded")
+		return
+	}
+
+	q.setStatus("Fetching messages...")
+	go func() {
+		messages, added, err := q.syncInbox(context.Background())
+		if err != nil {
+			q.setStatus("Fetching messages failed")
+			q.showError(fmt.Sprintf("Fetch error: %v", err))
+			return
+		}
+
+		q.setStatus(fmt.Sprintf("%d new messages", added))
+		fyne.Do(func() {
+			q.setInbox(messages)
+		})
+	}()
+}
+
+// showInboxMessage shows the selected message from the maildir and marks
+// it as read
+func (q *QuickMail) showInboxMessage(id widget.ListItemID) {
+	if id < 0 || id >= len(q.inbox) {
+		return
+	}
+	q.inboxSelected = id
+	message := q.inbox[id]
+
+	data, err := os.ReadFile(message.Path)
+	if err != nil {
+		q.inboxViewer.SetText("")
+		q.showError(fmt.Sprintf("Could not read message: %v", err))
+		return
+	}
+	q.inboxViewer.SetText(strings.ReplaceAll(string(data), "\r\n", "\n"))
+
+	if message.Seen {
+		return
+	}
+	box, err := q.maildir()
+	if err == nil {
+		message.Path, err = box.MarkSeen(message.Path)
+	}
+	if err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return
+	}
+	message.Seen = true
+	q.inbox[id] = message
+	q.inboxList.RefreshItem(id)
+}
+
+// showExportMboxDialog exports the maildir to an mbox file chosen by the
+// user
+func (q *QuickMail) showExportMboxDialog() {
+	box, err := q.maildir()
+	if err != nil {
+		q.showError(fmt.Sprintf("Export error: %v", err))
+		return
+	}
+
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			q.showError(fmt.Sprintf("Export error: %v", err))
+			return
+		}
+		if writer == nil {
+			return
+		}
+
+		err = box.ExportMbox(writer)
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			q.showError(fmt.Sprintf("Export error: %v", err))
+			return
+		}
+		q.setStatus("Exported to " + writer.URI().Path())
+	}, q.window)
+	saveDialog.SetFileName("quickmail.mbox")
+	saveDialog.Show()
+}
+
+// newInboxTab creates the Inbox tab with the message list and a read-only
+// viewer for the selected message
+func (q *QuickMail) newInboxTab() fyne.CanvasObject {
+	q.inboxList = widget.NewList(
+		func() int {
+			return len(q.inbox)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			message := q.inbox[id]
+			label := item.(*widget.Label)
+			text := fmt.Sprintf("%s  %d bytes", message.Date, message.Size)
+			if message.Remote {
+				text += "  (on server)"
+			}
+			label.TextStyle.Bold = !message.Seen
+			label.SetText(text)
+		},
+	)
+	q.inboxSelected = -1
+	q.inboxList.OnSelected = q.showInboxMessage
+	q.inboxList.OnUnselected = func(widget.ListItemID) {
+		q.inboxSelected = -1
+	}
+
+	q.inboxViewer = widget.NewLabel("")
+	q.inboxViewer.TextStyle = fyne.TextStyle{Monospace: true}
+	q.inboxViewer.Wrapping = fyne.TextWrapWord
+	q.inboxViewer.Selectable = true
+
+	q.loadInbox()
+
+	fetchButton := widget.NewButtonWithIcon("Fetch", theme.DownloadIcon(), q.refreshInbox)
+	deleteButton := widget.NewButtonWithIcon("Delete from Server", theme.DeleteIcon(), q.deleteSelectedMessage)
+	exportButton := widget.NewButtonWithIcon("Export mbox", theme.DocumentSaveIcon(), q.showExportMboxDialog)
+	replyButton := widget.NewButtonWithIcon("Reply", theme.MailReplyIcon(), q.replyToSelected)
+
+	split := container.NewHSplit(q.inboxList, container.NewScroll(q.inboxViewer))
+	split.Offset = 0.3
+
+	return container.NewBorder(
+		container.NewHBox(fetchButton, replyButton, deleteButton, exportButton),
+		nil,
+		nil,
+		nil,
+		split,
+	)
+}
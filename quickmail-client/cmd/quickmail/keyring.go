@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// KeyringEntry is a recipient public key stored under a name in
+// keyring.json, which lives next to the config file
+type KeyringEntry struct {
+	Name  string `json:"name"`
+	Armor string `json:"armor"`
+}
+
+// parsePublicKey parses an armored public key and checks that it can be
+// used for encryption
+func parsePublicKey(armored string) (openpgp.EntityList, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armored))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse key: %w", err)
+	}
+	if len(entities) == 0 {
+		return nil, errors.New("no keys found")
+	}
+	for _, entity := range entities {
+		if _, ok := entity.EncryptionKey(time.Now()); !ok {
+			return nil, unusableKeyError(entity, "encryption")
+		}
+	}
+	return entities, nil
+}
+
+// keyName suggests a keyring name for a key from its primary user ID
+func keyName(entity *openpgp.Entity) string {
+	if identity := entity.PrimaryIdentity(); identity != nil {
+		if identity.UserId != nil && identity.UserId.Email != "" {
+			return identity.UserId.Email
+		}
+		return identity.Name
+	}
+	return fmt.Sprintf("%X", entity.PrimaryKey.KeyId)
+}
+
+// keyringPath returns the location of keyring.json
+func (q *QuickMail) keyringPath() (string, error) {
+	dir, err := core.DataDir(q.config)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "keyring.json"), nil
+}
+
+// loadKeyring returns the stored recipient keys sorted by name; a missing
+// keyring is empty
+func (q *QuickMail) loadKeyring() ([]KeyringEntry, error) {
+	path, err := q.keyringPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []KeyringEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
+
+// saveKeyring writes the recipient keys to keyring.json
+func (q *QuickMail) saveKeyring(entries []KeyringEntry) error {
+	path, err := q.keyringPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return core.WriteFileAtomic(path, data, 0600)
+}
+
+// importKey adds an armored public key to the keyring under name,
+// replacing a key stored under the same name
+func (q *QuickMail) importKey(name, armored string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("the key needs a name")
+	}
+	if _, err := parsePublicKey(armored); err != nil {
+		return err
+	}
+
+	entries, err := q.loadKeyring()
+	if err != nil {
+		return err
+	}
+
+	entry := KeyringEntry{Name: name, Armor: armored}
+	replaced := false
+	for i := range entries {
+		if entries[i].Name == name {
+			entries[i] = entry
+			replaced = true
+		}
+	}
+	if !replaced {
+		entries = append(entries, entry)
+	}
+
+	return q.saveKeyring(entries)
+}
+
+// deleteKey removes the named key from the keyring
+func (q *QuickMail) deleteKey(name string) error {
+	entries, err := q.loadKeyring()
+	if err != nil {
+		return err
+	}
+
+	kept := entries[:0]
+	for _, entry := range entries {
+		if entry.Name != name {
+			kept = append(kept, entry)
+		}
+	}
+	return q.saveKeyring(kept)
+}
+
+// recipientKeys returns the keys the Encrypt option encrypts to: the key
+// selected from the keyring, or else the one at recipient_key_path
+func (q *QuickMail) recipientKeys() (openpgp.EntityList, error) {
+	if q.recipientKey == "" {
+		if q.config == nil {
+			return nil, errors.New("configuration not loaded")
+		}
+		return readPublicKeys(q.config.RecipientKeyPath)
+	}
+
+	entries, err := q.loadKeyring()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Name == q.recipientKey {
+			return parsePublicKey(entry.Armor)
+		}
+	}
+	return nil, fmt.Errorf("key %q is no longer in the keyring", q.recipientKey)
+}
+
+// refreshRecipients updates the recipient selector after the keyring
+// changed, keeping the selection if the key still exists
+func (q *QuickMail) refreshRecipients() {
+	if q.recipientSelect == nil {
+		return
+	}
+
+	entries, err := q.loadKeyring()
+	if err != nil {
+		fmt.Printf("Warning: Could not load keyring: %v\n", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	found := false
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+		found = found || entry.Name == q.recipientKey
+	}
+
+	q.recipientSelect.Options = names
+	if !found {
+		q.recipientKey = ""
+		q.recipientSelect.ClearSelected()
+	}
+	if len(names) == 0 {
+		q.recipientSelect.Hide()
+	} else {
+		q.recipientSelect.Show()
+	}
+	q.recipientSelect.Refresh()
+}
+
+// newRecipientSelect creates the selector for the keyring key the Encrypt
+// option encrypts to, hidden while the keyring is empty
+func (q *QuickMail) newRecipientSelect() *widget.Select {
+	q.recipientSelect = widget.NewSelect(nil, func(name string) {
+		q.recipientKey = name
+	})
+	q.recipientSelect.PlaceHolder = "Recipient key"
+	q.refreshRecipients()
+	return q.recipientSelect
+}
+
+// showKeyringDialog lists the recipient keys and lets the user import keys
+// from a file or pasted armor and delete them
+func (q *QuickMail) showKeyringDialog() {
+	entries, err := q.loadKeyring()
+	if err != nil {
+		q.showError(fmt.Sprintf("Keyring error: %v", err))
+		return
+	}
+
+	selected := -1
+	list := widget.NewList(
+		func() int {
+			return len(entries)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			item.(*widget.Label).SetText(entries[id].Name)
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		selected = id
+	}
+
+	reload := func() {
+		if updated, err := q.loadKeyring(); err == nil {
+			entries = updated
+		}
+		selected = -1
+		list.UnselectAll()
+		list.Refresh()
+		q.refreshRecipients()
+	}
+
+	importButton := widget.NewButton("Import File...", func() {
+		dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+			if err != nil {
+				q.showError(fmt.Sprintf("Could not open file: %v", err))
+				return
+			}
+			if reader == nil {
+				return
+			}
+			defer reader.Close()
+
+			data, err := io.ReadAll(reader)
+			if err != nil {
+				q.showError(fmt.Sprintf("Could not read key: %v", err))
+				return
+			}
+			q.showImportKeyDialog(string(bytes.TrimSpace(data)), reload)
+		}, q.window)
+	})
+
+	pasteButton := widget.NewButton("Paste Key...", func() {
+		q.showImportKeyDialog("", reload)
+	})
+
+	deleteButton := widget.NewButton("Delete", func() {
+		if selected < 0 || selected >= len(entries) {
+			return
+		}
+		if err := q.deleteKey(entries[selected].Name); err != nil {
+			q.showError(fmt.Sprintf("Could not delete key: %v", err))
+			return
+		}
+		reload()
+	})
+
+	content := container.NewBorder(
+		nil,
+		container.NewHBox(importButton, pasteButton, deleteButton),
+		nil,
+		nil,
+		list,
+	)
+
+	keyringDialog := dialog.NewCustom("Recipient Keys", "Close", content, q.window)
+	keyringDialog.Resize(fyne.NewSize(500, 400))
+	keyringDialog.Show()
+}
+
+// showImportKeyDialog asks for the name and armor of a key to import,
+// suggesting the name from the key's user ID, and calls done after the key
+// was stored
+func (q *QuickMail) showImportKeyDialog(armored string, done func()) {
+	nameEntry := widget.NewEntry()
+	armorEntry := widget.NewMultiLineEntry()
+	armorEntry.PlaceHolder = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+	armorEntry.SetMinRowsVisible(8)
+	armorEntry.OnChanged = func(text string) {
+		if nameEntry.Text != "" {
+			return
+		}
+		if entities, err := parsePublicKey(text); err == nil {
+			nameEntry.SetText(keyName(entities[0]))
+		}
+	}
+	armorEntry.SetText(armored)
+
+	importDialog := dialog.NewForm(
+		"Import Recipient Key",
+		"Import",
+		"Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("Name:", nameEntry),
+			widget.NewFormItem("Key:", armorEntry),
+		},
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+			if err := q.importKey(nameEntry.Text, armorEntry.Text); err != nil {
+				q.showError(fmt.Sprintf("Could not import key: %v", err))
+				return
+			}
+			done()
+		},
+		q.window,
+	)
+
+	importDialog.Show()
+	importDialog.Resize(fyne.NewSize(600, 400))
+}
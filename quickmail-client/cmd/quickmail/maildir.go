@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Downloaded messages are kept in a maildir next to the config, so the
+// inbox can be read offline. Messages are delivered to new/ and moved to
+// cur/ with the S (seen) flag once they have been opened.
+
+// maildirIDHeader is added to delivered messages and records their id on
+// the server, so they are not downloaded twice
+const maildirIDHeader = "X-QuickMail-Id"
+
+// maildirCounter makes unique names of deliveries within one second unique
+var maildirCounter atomic.Uint64
+
+// Maildir is a mailbox in maildir format: a directory with the
+// subdirectories tmp, new and cur
+type Maildir string
+
+// openMaildir returns the maildir at root, creating it if necessary
+func openMaildir(root string) (Maildir, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(root, sub), 0700); err != nil {
+			return "", fmt.Errorf("could not create maildir: %w", err)
+		}
+	}
+	return Maildir(root), nil
+}
+
+// maildirInfo separates the unique name of a message from its flags.
+// Windows does not allow ":" in file names, so "!" is used there instead,
+// as other maildir programs on Windows do.
+func maildirInfo() string {
+	if runtime.GOOS == "windows" {
+		return "!2,"
+	}
+	return ":2,"
+}
+
+// maildirUniqueName returns a file name following the maildir convention
+// time.MusecPpidQcount.host
+func maildirUniqueName() string {
+	now := time.Now()
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	host = strings.NewReplacer("/", `\057`, ":", `\072`).Replace(host)
+	return fmt.Sprintf("%d.M%dP%dQ%d.%s", now.Unix(), now.Nanosecond()/1000, os.Getpid(), maildirCounter.Add(1), host)
+}
+
+// splitMaildirName returns the unique name and the flags of a message
+// file name
+func splitMaildirName(name string) (unique, flags string) {
+	if i := strings.LastIndex(name, maildirInfo()); i >= 0 {
+		return name[:i], name[i+len(maildirInfo()):]
+	}
+	return name, ""
+}
+
+// Deliver stores message in new/, tagged with its id on the server, and
+// returns its path. The message is written to tmp/ and only moved to new/
+// once it is completely on disk.
+func (m Maildir) Deliver(id string, message []byte) (string, error) {
+	if id == "" || strings.ContainsAny(id, "\r\n") {
+		return "", fmt.Errorf("invalid message id %q", id)
+	}
+
+	newline := "\n"
+	if bytes.Contains(message, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+
+	name := maildirUniqueName()
+	tmp := filepath.Join(string(m), "tmp", name)
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", fmt.Errorf("could not store message: %w", err)
+	}
+
+	_, err = io.WriteString(f, maildirIDHeader+": "+id+newline)
+	if err == nil {
+		_, err = f.Write(message)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("could not store message: %w", err)
+	}
+
+	path := filepath.Join(string(m), "new", name)
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("could not store message: %w", err)
+	}
+	return path, nil
+}
+
+// List returns the messages in new/ and cur/, newest first. Files that
+// cannot be read are skipped.
+func (m Maildir) List() ([]InboxMessage, error) {
+	var messages []InboxMessage
+	for _, sub := range []string{"new", "cur"} {
+		entries, err := os.ReadDir(filepath.Join(string(m), sub))
+		if err != nil {
+			return nil, fmt.Errorf("could not read maildir: %w", err)
+		}
+
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			path := filepath.Join(string(m), sub, entry.Name())
+			message, err := readMaildirMessage(path)
+			if err != nil {
+				fmt.Printf("Warning: Skipping %s: %v\n", path, err)
+				continue
+			}
+			_, flags := splitMaildirName(entry.Name())
+			message.Seen = sub == "cur" && strings.Contains(flags, "S")
+			messages = append(messages, message)
+		}
+	}
+
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].received.After(messages[j].received)
+	})
+	return messages, nil
+}
+
+// readMaildirMessage reads the id, date and size of a stored message
+func readMaildirMessage(path string) (InboxMessage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return InboxMessage{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return InboxMessage{}, err
+	}
+
+	message, err := mail.ReadMessage(bufio.NewReader(f))
+	if err != nil {
+		return InboxMessage{}, err
+	}
+
+	id := message.Header.Get(maildirIDHeader)
+	if id == "" {
+		return InboxMessage{}, errors.New("not downloaded by QuickMail")
+	}
+
+	date := info.ModTime()
+	if sent, err := message.Header.Date(); err == nil {
+		date = sent
+	}
+
+	return InboxMessage{
+		ID:   id,
+		Size: info.Size(),
+		Date: date.Local().Format("2006-01-02 15:04"),
+		Path: path,
+
+		received: info.ModTime(),
+	}, nil
+}
+
+// MarkSeen moves a message to cur/ and adds the S flag, returning its new
+// path
+func (m Maildir) MarkSeen(path string) (string, error) {
+	unique, flags := splitMaildirName(filepath.Base(path))
+	if filepath.Base(filepath.Dir(path)) == "cur" && strings.Contains(flags, "S") {
+		return path, nil
+	}
+
+	if !strings.Contains(flags, "S") {
+		// Flags are kept in ASCII order
+		runes := []rune(flags + "S")
+		sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+		flags = string(runes)
+	}
+
+	target := filepath.Join(string(m), "cur", unique+maildirInfo()+flags)
+	if err := os.Rename(path, target); err != nil {
+		return "", fmt.Errorf("could not mark message as read: %w", err)
+	}
+	return target, nil
+}
+
+// ExportMbox writes all messages, oldest first, to w as an mboxrd file as
+// read by common mail clients. Lines starting with "From ", after any
+// number of ">", get one more ">".
+func (m Maildir) ExportMbox(w io.Writer) error {
+	messages, err := m.List()
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(w)
+	for i := len(messages) - 1; i >= 0; i-- {
+		data, err := os.ReadFile(messages[i].Path)
+		if err != nil {
+			return fmt.Errorf("could not read message: %w", err)
+		}
+
+		fmt.Fprintf(out, "From MAILER-DAEMON %s\n", messages[i].received.UTC().Format(time.ANSIC))
+
+		text := strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+		for _, line := range strings.Split(text, "\n") {
+			if strings.HasPrefix(strings.TrimLeft(line, ">"), "From ") {
+				out.WriteString(">")
+			}
+			out.WriteString(line + "\n")
+		}
+		out.WriteString("\n")
+	}
+	return out.Flush()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+
+	"quickmail/core"
+)
+
+// parseMailto parses a mailto: URI as described in RFC 2368 and returns the
+// recipients, subject and body. Recipients given in the path and in "to"
+// fields are joined with commas. Unlike in web forms, "+" in a mailto: URI
+// is a literal plus sign and not a space.
+func parseMailto(uri string) (to, subject, body string, err error) {
+	scheme, rest, ok := strings.Cut(uri, ":")
+	if !ok || !strings.EqualFold(scheme, "mailto") {
+		return "", "", "", errors.New("not a mailto: URI")
+	}
+
+	path, query, _ := strings.Cut(rest, "?")
+
+	var recipients []string
+	if path != "" {
+		address, err := url.PathUnescape(path)
+		if err != nil {
+			return "", "", "", fmt.Errorf("invalid recipient: %w", err)
+		}
+		recipients = append(recipients, address)
+	}
+
+	for _, field := range strings.Split(query, "&") {
+		if field == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(field, "=")
+		value, err := url.PathUnescape(value)
+		if err != nil {
+			return "", "", "", fmt.Errorf("invalid %s field: %w", name, err)
+		}
+
+		switch strings.ToLower(name) {
+		case "to":
+			recipients = append(recipients, value)
+		case "subject":
+			subject = value
+		case "body":
+			body = value
+		}
+	}
+
+	return strings.Join(recipients, ", "), subject, body, nil
+}
+
+// mailtoStub returns a minimal message with the headers and body taken from
+// a mailto: URI, encoding the subject with encoding
+func mailtoStub(to, subject, body string, encoding mime.WordEncoder) string {
+	var stub strings.Builder
+	if to != "" {
+		stub.WriteString("To: " + to + "\n")
+	}
+	if subject != "" {
+		stub.WriteString("Subject: " + core.EncodeMIMESubject(subject, encoding) + "\n")
+	}
+	stub.WriteString("\n" + strings.ReplaceAll(body, "\r\n", "\n"))
+	return stub.String()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"mime"
+	"testing"
+)
+
+func TestParseMailto(t *testing.T) {
+	tests := []struct {
+		name              string
+		uri               string
+		to, subject, body string
+		wantErr           bool
+	}{
+		{name: "address only", uri: "mailto:alice@example.org", to: "alice@example.org"},
+		{name: "scheme is case-insensitive", uri: "MAILTO:alice@example.org", to: "alice@example.org"},
+		{name: "empty", uri: "mailto:"},
+		{
+			name:    "subject and body",
+			uri:     "mailto:alice@example.org?subject=Hello%20there&body=Line%20one%0D%0ALine%20two",
+			to:      "alice@example.org",
+			subject: "Hello there",
+			body:    "Line one\r\nLine two",
+		},
+		{
+			name: "path and to fields are joined",
+			uri:  "mailto:alice@example.org?to=bob@example.org&TO=carol@example.org",
+			to:   "alice@example.org, bob@example.org, carol@example.org",
+		},
+		{name: "recipients only in to field", uri: "mailto:?to=bob@example.org", to: "bob@example.org"},
+		{name: "escaped path", uri: "mailto:alice%40example.org", to: "alice@example.org"},
+		{name: "plus is literal", uri: "mailto:a+tag@example.org?subject=1+1", to: "a+tag@example.org", subject: "1+1"},
+		{name: "utf-8 subject", uri: "mailto:x@example.org?subject=Gr%C3%BC%C3%9Fe", to: "x@example.org", subject: "Grüße"},
+		{name: "unknown and empty fields", uri: "mailto:x@example.org?cc=y@example.org&&body=hi", to: "x@example.org", body: "hi"},
+		{name: "not mailto", uri: "https://example.org", wantErr: true},
+		{name: "no scheme", uri: "alice@example.org", wantErr: true},
+		{name: "bad escape in path", uri: "mailto:alice%zz", wantErr: true},
+		{name: "bad escape in field", uri: "mailto:x@example.org?subject=%e", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			to, subject, body, err := parseMailto(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMailto(%q) error = %v, want error %v", tt.uri, err, tt.wantErr)
+			}
+			if to != tt.to || subject != tt.subject || body != tt.body {
+				t.Errorf("parseMailto(%q) = %q, %q, %q, want %q, %q, %q",
+					tt.uri, to, subject, body, tt.to, tt.subject, tt.body)
+			}
+		})
+	}
+}
+
+func TestMailtoStub(t *testing.T) {
+	tests := []struct {
+		name              string
+		to, subject, body string
+		want              string
+	}{
+		{"empty", "", "", "", "\n"},
+		{"all fields", "alice@example.org", "Hi", "Line one\r\nLine two", "To: alice@example.org\nSubject: Hi\n\nLine one\nLine two"},
+		{"encoded subject", "", "Grüße", "", "Subject: =?UTF-8?B?R3LDvMOfZQ==?=\n\n"},
+	}
+
+	for _, tt := range tests {
+		if got := mailtoStub(tt.to, tt.subject, tt.body, mime.BEncoding); got != tt.want {
+			t.Errorf("%s: mailtoStub = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
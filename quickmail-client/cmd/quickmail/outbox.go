@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// OutboxEntry is a message whose upload failed, stored as one JSON file in
+// the outbox directory until it is sent or deleted
+type OutboxEntry struct {
+	Server    string    `json:"server"`
+	ServerURL string    `json:"server_url"`
+	Message   string    `json:"message"`
+	Bcc       []string  `json:"bcc,omitempty"`
+	Queued    time.Time `json:"queued"`
+
+	// file is the name of the entry within the outbox directory
+	file string
+}
+
+// subject returns the Subject header of the queued message for display
+func (e *OutboxEntry) subject() string {
+	if subject := core.MessageSubject(e.Message); subject != "" {
+		return subject
+	}
+	return "(no subject)"
+}
+
+// outboxDir returns the outbox directory, creating it if necessary
+func (q *QuickMail) outboxDir() (string, error) {
+	dir, err := core.DataDir(q.config)
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "outbox")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("could not create outbox: %w", err)
+	}
+	return dir, nil
+}
+
+// queueMessage stores a message in the outbox
+func (q *QuickMail) queueMessage(entry OutboxEntry) error {
+	dir, err := q.outboxDir()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(entry, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	// Zero-padded timestamps keep the file names in queue order
+	name := fmt.Sprintf("%020d.json", entry.Queued.UnixNano())
+	if err := core.WriteFileAtomic(filepath.Join(dir, name), data, 0600); err != nil {
+		return err
+	}
+
+	q.refreshOutbox()
+	return nil
+}
+
+// queueJob adds a prepared message to the outbox, where it waits for Send
+// Queue
+func (q *QuickMail) queueJob(job sendJob) {
+	defer job.body.Destroy()
+
+	err := q.queueMessage(OutboxEntry{
+		Server:    job.serverName,
+		ServerURL: job.url,
+		Message:   string(job.body.Bytes()),
+		Bcc:       job.bcc,
+		Queued:    time.Now(),
+	})
+	if err != nil {
+		q.showError(fmt.Sprintf("Could not add the message to the queue: %v", err))
+		return
+	}
+	q.deleteDraft(job.original)
+
+	count := 0
+	if entries, err := q.loadOutbox(); err == nil {
+		count = len(entries)
+	}
+	q.setStatus(fmt.Sprintf("Message added to the queue, %d waiting", count))
+}
+
+// loadOutbox returns the queued messages, oldest first
+func (q *QuickMail) loadOutbox() ([]OutboxEntry, error) {
+	dir, err := q.outboxDir()
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	entries := make([]OutboxEntry, 0, len(names))
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("could not read outbox entry: %w", err)
+		}
+
+		var entry OutboxEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			fmt.Printf("Warning: Skipping unreadable outbox entry %s: %v\n", name, err)
+			continue
+		}
+		entry.file = filepath.Base(name)
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// removeFromOutbox deletes a queued message
+func (q *QuickMail) removeFromOutbox(entry OutboxEntry) error {
+	dir, err := q.outboxDir()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, entry.file)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	q.refreshOutbox()
+	return nil
+}
+
+// flushOutbox sends the queued messages in order, removing each one only
+// after the server accepted it. It stops at the first failure so the queue
+// order is kept; the failed message and the ones after it stay queued for
+// the next try and are reported as not tried.
+// All messages of a run share one client, so with isolate_streams they
+// leave over one Tor circuit of their own. The run uses config, a snapshot
+// taken before it started, and stops when ctx is cancelled. Errors are
+// shown in a dialog when interactive is set, and report, if not nil,
+// receives the status of each message as it changes.
+func (q *QuickMail) flushOutbox(ctx context.Context, config *core.Config, interactive bool, report func(entry OutboxEntry, status string)) {
+	if report == nil {
+		report = func(OutboxEntry, string) {}
+	}
+
+	q.outboxMu.Lock()
+	defer q.outboxMu.Unlock()
+
+	entries, err := q.loadOutbox()
+	if err != nil {
+		if interactive {
+			q.showError(fmt.Sprintf("Outbox error: %v", err))
+		}
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	policy := core.DefaultRetryPolicy
+	if config != nil {
+		policy = config.RetryPolicy()
+	}
+
+	client, err := q.sendClient(config)
+	if err != nil {
+		if interactive {
+			q.showError(fmt.Sprintf("Could not send queued messages: %v", err))
+		}
+		return
+	}
+
+	// notTried reports the messages after the i-th, which stay queued
+	notTried := func(i int) {
+		for _, entry := range entries[i+1:] {
+			report(entry, "Not tried")
+		}
+	}
+
+	sent := 0
+	for i, entry := range entries {
+		q.setStatus(fmt.Sprintf("Sending queued message %d of %d...", sent+1, len(entries)))
+		report(entry, "Sending...")
+		body := lockMessage(entry.Message)
+		err := q.uploadMessageWith(ctx, client, config, entry.ServerURL, body.Bytes(), entry.Bcc, policy)
+		body.Destroy()
+		if errors.Is(err, context.Canceled) {
+			report(entry, "Cancelled")
+			notTried(i)
+			q.setStatus(fmt.Sprintf("Outbox: %d of %d queued messages sent, cancelled", sent, len(entries)))
+			return
+		}
+		if err != nil {
+			report(entry, fmt.Sprintf("Failed, kept for retry: %v", err))
+			notTried(i)
+			q.setStatus(fmt.Sprintf("Outbox: %d of %d queued messages sent", sent, len(entries)))
+			if interactive {
+				q.showError(fmt.Sprintf("Could not send queued message: %v", err))
+			}
+			return
+		}
+		if err := q.removeFromOutbox(entry); err != nil {
+			fmt.Printf("Warning: Could not remove sent message from outbox: %v\n", err)
+		}
+		report(entry, "Sent")
+		sent++
+	}
+
+	q.setStatus(fmt.Sprintf("Outbox: all %d queued messages sent", sent))
+}
+
+// refreshOutbox updates the outbox button with the number of queued
+// messages; it may be called from any goroutine
+func (q *QuickMail) refreshOutbox() {
+	if q.outboxButton == nil {
+		return
+	}
+
+	count := 0
+	if entries, err := q.loadOutbox(); err == nil {
+		count = len(entries)
+	}
+
+	fyne.Do(func() {
+		q.outboxButton.SetText(fmt.Sprintf("Outbox (%d)", count))
+	})
+}
+
+// showOutboxDialog lists the queued messages and lets the user send or
+// delete them. While Send Queue runs each message shows its status, and
+// Cancel stops the run.
+func (q *QuickMail) showOutboxDialog() {
+	entries, err := q.loadOutbox()
+	if err != nil {
+		q.showError(fmt.Sprintf("Outbox error: %v", err))
+		return
+	}
+
+	selected := -1
+	statuses := make(map[string]string)
+	list := widget.NewList(
+		func() int {
+			return len(entries)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			entry := entries[id]
+			text := fmt.Sprintf("%s  %s  %s",
+				entry.Queued.Local().Format("2006-01-02 15:04"), entry.Server, entry.subject())
+			if status := statuses[entry.file]; status != "" {
+				text += "  " + status
+			}
+			item.(*widget.Label).SetText(text)
+		},
+	)
+	list.OnSelected = func(id widget.ListItemID) {
+		selected = id
+	}
+
+	var outboxDialog dialog.Dialog
+
+	deleteButton := widget.NewButton("Delete", func() {
+		if selected < 0 || selected >= len(entries) {
+			return
+		}
+		if err := q.removeFromOutbox(entries[selected]); err != nil {
+			q.showError(fmt.Sprintf("Could not delete queued message: %v", err))
+			return
+		}
+		entries = append(entries[:selected], entries[selected+1:]...)
+		selected = -1
+		list.UnselectAll()
+		list.Refresh()
+	})
+
+	var cancelRun context.CancelFunc
+	cancelButton := widget.NewButton("Cancel", func() {
+		if cancelRun != nil {
+			cancelRun()
+		}
+	})
+	cancelButton.Disable()
+
+	var sendButton *widget.Button
+	sendButton = widget.NewButton("Send Queue", func() {
+		sendButton.Disable()
+		deleteButton.Disable()
+		cancelButton.Enable()
+
+		ctx, cancel := context.WithCancel(q.ctx)
+		cancelRun = cancel
+		config := q.currentConfig()
+		go func() {
+			defer cancel()
+			q.flushOutbox(ctx, config, true, func(entry OutboxEntry, status string) {
+				fyne.Do(func() {
+					statuses[entry.file] = status
+					list.Refresh()
+				})
+			})
+			// Sent messages left the outbox; the list shows what is left
+			remaining, err := q.loadOutbox()
+			fyne.Do(func() {
+				if err == nil {
+					entries = remaining
+					selected = -1
+					list.UnselectAll()
+					list.Refresh()
+				}
+				if len(entries) > 0 {
+					sendButton.Enable()
+				}
+				deleteButton.Enable()
+				cancelButton.Disable()
+			})
+		}()
+	})
+	if len(entries) == 0 {
+		sendButton.Disable()
+	}
+
+	content := container.NewBorder(
+		nil,
+		container.NewHBox(sendButton, cancelButton, deleteButton),
+		nil,
+		nil,
+		list,
+	)
+
+	outboxDialog = dialog.NewCustom("Outbox", "Close", content, q.window)
+	outboxDialog.Resize(fyne.NewSize(600, 400))
+	outboxDialog.Show()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"quickmail/core"
+)
+
+// newTestOutbox returns a QuickMail sending directly, without Tor, whose
+// config and outbox live in a temporary directory
+func newTestOutbox(t *testing.T) *QuickMail {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Chdir(dir)
+
+	const config = `{
+    "servers": [{"name": "default", "onion_address": "http://127.0.0.1", "port": "8088"}],
+    "proxy_type": "direct",
+    "max_retries": 0
+}`
+	if err := os.WriteFile(filepath.Join(dir, "quickmail.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := core.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &QuickMail{config: loaded}
+}
+
+func TestFlushOutboxStopsAtFailure(t *testing.T) {
+	// The second upload fails, the others would succeed
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 2 {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	q := newTestOutbox(t)
+	queued := time.Now()
+	for i, subject := range []string{"First", "Second", "Third"} {
+		err := q.queueMessage(OutboxEntry{
+			Server:    "default",
+			ServerURL: server.URL + "/upload",
+			Message:   "Subject: " + subject + "\n\nbody\n",
+			Queued:    queued.Add(time.Duration(i) * time.Second),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	statuses := make(map[string]string)
+	q.flushOutbox(t.Context(), q.config, false, func(entry OutboxEntry, status string) {
+		statuses[entry.subject()] = status
+	})
+
+	if n := requests.Load(); n != 2 {
+		t.Errorf("server received %d uploads, want 2", n)
+	}
+	if statuses["First"] != "Sent" {
+		t.Errorf("First is %q, want Sent", statuses["First"])
+	}
+	if !strings.HasPrefix(statuses["Second"], "Failed") {
+		t.Errorf("Second is %q, want Failed", statuses["Second"])
+	}
+	if statuses["Third"] != "Not tried" {
+		t.Errorf("Third is %q, want Not tried", statuses["Third"])
+	}
+
+	entries, err := q.loadOutbox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	for _, entry := range entries {
+		left = append(left, entry.subject())
+	}
+	if strings.Join(left, ",") != "Second,Third" {
+		t.Errorf("outbox holds %v, want the failed message and the one after it", left)
+	}
+}
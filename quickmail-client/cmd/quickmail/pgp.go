@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// readSecretKey reads the first entity with a private key from an armored
+// key file
+func readSecretKey(keyPath string) (*openpgp.Entity, error) {
+	if keyPath == "" {
+		return nil, errors.New("no signing key configured (signing_key_path)")
+	}
+
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read signing key: %w", err)
+	}
+
+	entity, err := parseSecretKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", keyPath, err)
+	}
+	return entity, nil
+}
+
+// parseSecretKey returns the first entity with a private key from armored
+// key data
+func parseSecretKey(data []byte) (*openpgp.Entity, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse signing key: %w", err)
+	}
+
+	for _, entity := range entities {
+		if entity.PrivateKey != nil {
+			return entity, nil
+		}
+	}
+	return nil, errors.New("no secret key found")
+}
+
+// unusableKeyError explains why entity has no valid subkey for purpose
+// ("signing" or "encryption") in terms a user can act on
+func unusableKeyError(entity *openpgp.Entity, purpose string) error {
+	now := time.Now()
+	id := fmt.Sprintf("%X", entity.PrimaryKey.KeyId)
+
+	if entity.Revoked(now) {
+		return fmt.Errorf("key %s has been revoked", id)
+	}
+	if identity := entity.PrimaryIdentity(); identity != nil && identity.SelfSignature != nil {
+		sig := identity.SelfSignature
+		if sig.KeyLifetimeSecs != nil && entity.PrimaryKey.KeyExpired(sig, now) {
+			expiry := entity.PrimaryKey.CreationTime.Add(time.Duration(*sig.KeyLifetimeSecs) * time.Second)
+			return fmt.Errorf("key %s expired on %s", id, expiry.Format("2006-01-02"))
+		}
+	}
+	return fmt.Errorf("key %s has no valid %s subkey, it may have expired", id, purpose)
+}
+
+// signingKey returns the signing subkey of entity
+func signingKey(entity *openpgp.Entity) (*packet.PrivateKey, error) {
+	key, ok := entity.SigningKey(time.Now())
+	if !ok || key.PrivateKey == nil {
+		return nil, unusableKeyError(entity, "signing")
+	}
+	return key.PrivateKey, nil
+}
+
+// signingKeyLocked reports whether the signing key at keyPath is protected
+// by a passphrase
+func signingKeyLocked(keyPath string) (bool, error) {
+	entity, err := readSecretKey(keyPath)
+	if err != nil {
+		return false, err
+	}
+
+	key, err := signingKey(entity)
+	if err != nil {
+		return false, err
+	}
+	return key.Encrypted, nil
+}
+
+// readSigningKey reads the secret key at keyPath, unlocks it with
+// passphrase if it is protected and returns the entity together with its
+// signing subkey
+func readSigningKey(keyPath string, passphrase []byte) (*openpgp.Entity, *packet.PrivateKey, error) {
+	entity, err := readSecretKey(keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := signingKey(entity)
+	if err != nil {
+		return nil, nil, err
+	}
+	if key.Encrypted {
+		if len(passphrase) == 0 {
+			return nil, nil, errors.New("signing key is protected by a passphrase")
+		}
+		if err := entity.DecryptPrivateKeys(passphrase); err != nil {
+			return nil, nil, errors.New("wrong passphrase for signing key")
+		}
+	}
+
+	return entity, key, nil
+}
+
+// wipe overwrites a passphrase in memory
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// askPassphrase prompts for the signing key passphrase and passes it to
+// onConfirm; the passphrase is never stored
+func (q *QuickMail) askPassphrase(onConfirm func(passphrase []byte)) {
+	passphraseEntry := widget.NewPasswordEntry()
+
+	passphraseDialog := dialog.NewForm(
+		"Signing Key Passphrase",
+		"Sign",
+		"Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("Passphrase:", passphraseEntry),
+		},
+		func(confirmed bool) {
+			passphrase := []byte(passphraseEntry.Text)
+			passphraseEntry.SetText("")
+			if !confirmed {
+				wipe(passphrase)
+				return
+			}
+			onConfirm(passphrase)
+		},
+		q.window,
+	)
+
+	passphraseDialog.Show()
+	passphraseDialog.Resize(fyne.NewSize(400, 150))
+}
+
+// readPublicKeys reads the recipient keys from an armored key file
+func readPublicKeys(keyPath string) (openpgp.EntityList, error) {
+	if keyPath == "" {
+		return nil, errors.New("no recipient key configured (recipient_key_path)")
+	}
+
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read recipient key: %w", err)
+	}
+
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse recipient key: %w", err)
+	}
+	if len(entities) == 0 {
+		return nil, fmt.Errorf("%s contains no keys", keyPath)
+	}
+	for _, entity := range entities {
+		if _, ok := entity.EncryptionKey(time.Now()); !ok {
+			return nil, unusableKeyError(entity, "encryption")
+		}
+	}
+
+	return entities, nil
+}
+
+// signMessage returns plaintext as an ASCII-armored cleartext-signed block
+// using the secret key stored at keyPath
+func signMessage(plaintext string, keyPath string, passphrase []byte) (string, error) {
+	_, privateKey, err := readSigningKey(keyPath, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	w, err := clearsign.Encode(&buf, privateKey, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not sign message: %w", err)
+	}
+	if _, err := w.Write([]byte(plaintext)); err != nil {
+		w.Close()
+		return "", fmt.Errorf("could not sign message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("could not sign message: %w", err)
+	}
+
+	return buf.String() + "\n", nil
+}
+
+// encryptMessage returns plaintext as an ASCII-armored PGP message
+// encrypted to the recipient keys
+func encryptMessage(plaintext string, recipients openpgp.EntityList) (string, error) {
+	return encrypt(plaintext, recipients, nil)
+}
+
+// signAndEncryptMessage signs plaintext with the secret key at
+// signingKeyPath and encrypts the signed message to the recipient keys, so
+// the signature is only visible to the recipients
+func signAndEncryptMessage(plaintext string, recipients openpgp.EntityList, signingKeyPath string, passphrase []byte) (string, error) {
+	signer, _, err := readSigningKey(signingKeyPath, passphrase)
+	if err != nil {
+		return "", err
+	}
+	return encrypt(plaintext, recipients, signer)
+}
+
+// encrypt encrypts plaintext to the recipient keys, signing it with signer
+// if it is not nil
+func encrypt(plaintext string, recipients openpgp.EntityList, signer *openpgp.Entity) (string, error) {
+	var buf bytes.Buffer
+	armored, err := armor.Encode(&buf, "PGP MESSAGE", nil)
+	if err != nil {
+		return "", fmt.Errorf("could not encrypt message: %w", err)
+	}
+
+	w, err := openpgp.Encrypt(armored, recipients, signer, nil, nil)
+	if err != nil {
+		armored.Close()
+		return "", fmt.Errorf("could not encrypt message: %w", err)
+	}
+	if _, err := w.Write([]byte(plaintext)); err != nil {
+		w.Close()
+		armored.Close()
+		return "", fmt.Errorf("could not encrypt message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		armored.Close()
+		return "", fmt.Errorf("could not encrypt message: %w", err)
+	}
+	if err := armored.Close(); err != nil {
+		return "", fmt.Errorf("could not encrypt message: %w", err)
+	}
+
+	return buf.String() + "\n", nil
+}
+
+// showImportSigningKeyDialog copies an armored secret key into the config
+// directory and makes it the signing key. The key is stored as it is, so a
+// passphrase-protected key stays protected.
+func (q *QuickMail) showImportSigningKeyDialog() {
+	if q.config == nil {
+		q.showError("Configuration not loaded")
+		return
+	}
+
+	dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			q.showError(fmt.Sprintf("Could not open file: %v", err))
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			q.showError(fmt.Sprintf("Could not read key: %v", err))
+			return
+		}
+		entity, err := parseSecretKey(data)
+		if err == nil {
+			_, err = signingKey(entity)
+		}
+		if err != nil {
+			q.showError(fmt.Sprintf("Could not import signing key: %v", err))
+			return
+		}
+
+		dir, err := core.DataDir(q.config)
+		if err != nil {
+			q.showError(fmt.Sprintf("Could not import signing key: %v", err))
+			return
+		}
+		path := filepath.Join(dir, "signing-key.asc")
+		if err := core.WriteFileAtomic(path, data, 0600); err != nil {
+			q.showError(fmt.Sprintf("Could not import signing key: %v", err))
+			return
+		}
+
+		q.config.SigningKeyPath = path
+		if err := core.SaveConfig(q.config); err != nil {
+			q.showError(fmt.Sprintf("Could not save settings: %v", err))
+			return
+		}
+		q.showInfo("Signing Key", fmt.Sprintf("Imported signing key %s.", keyName(entity)))
+	}, q.window)
+}
+
+// checkRecipientKey verifies that the recipient key can be loaded before
+// the Encrypt option is enabled
+func (q *QuickMail) checkRecipientKey() error {
+	_, err := q.recipientKeys()
+	return err
+}
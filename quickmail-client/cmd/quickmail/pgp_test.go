@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// newTestKey generates an ephemeral key pair and writes its armored public
+// and secret key to files in a temporary directory
+func newTestKey(t *testing.T, name string) (entity *openpgp.Entity, publicPath, secretPath string) {
+	t.Helper()
+	entity, err := openpgp.NewEntity(name, "", name+"@example.org", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	dir := t.TempDir()
+	publicPath = filepath.Join(dir, name+".asc")
+	secretPath = filepath.Join(dir, name+"-secret.asc")
+	writeArmored(t, publicPath, openpgp.PublicKeyType, entity.Serialize)
+	writeArmored(t, secretPath, openpgp.PrivateKeyType, func(w io.Writer) error {
+		return entity.SerializePrivate(w, nil)
+	})
+	return entity, publicPath, secretPath
+}
+
+func writeArmored(t *testing.T, path, blockType string, serialize func(io.Writer) error) {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, blockType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// decryptTestMessage decrypts an armored PGP message with keys and returns
+// the plaintext and the metadata describing its signature
+func decryptTestMessage(t *testing.T, armored string, keys openpgp.EntityList) (string, *openpgp.MessageDetails) {
+	t.Helper()
+	block, err := armor.Decode(strings.NewReader(armored))
+	if err != nil {
+		t.Fatalf("decoding armor: %v", err)
+	}
+	if block.Type != "PGP MESSAGE" {
+		t.Fatalf("armor type = %q, want PGP MESSAGE", block.Type)
+	}
+
+	md, err := openpgp.ReadMessage(block.Body, keys, nil, nil)
+	if err != nil {
+		t.Fatalf("reading message: %v", err)
+	}
+	plaintext, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		t.Fatalf("reading plaintext: %v", err)
+	}
+	return string(plaintext), md
+}
+
+func TestEncryptMessageRoundTrip(t *testing.T) {
+	recipient, publicPath, _ := newTestKey(t, "alice")
+	signer, _, signerSecret := newTestKey(t, "bob")
+
+	recipients, err := readPublicKeys(publicPath)
+	if err != nil {
+		t.Fatalf("readPublicKeys: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		plaintext string
+		sign      bool
+	}{
+		{"ascii", "Hello, World!\n", false},
+		{"utf-8", "Grüße aus Köln 🔒\n", false},
+		{"no trailing newline", "line one\nline two", false},
+		{"empty", "", false},
+		{"signed", "Signed and sealed.\n", true},
+		{"signed utf-8", "Schöne Grüße\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var armored string
+			var err error
+			if tt.sign {
+				armored, err = signAndEncryptMessage(tt.plaintext, recipients, signerSecret, nil)
+			} else {
+				armored, err = encryptMessage(tt.plaintext, recipients)
+			}
+			if err != nil {
+				t.Fatalf("encrypting: %v", err)
+			}
+			if !strings.HasPrefix(armored, "-----BEGIN PGP MESSAGE-----") {
+				t.Errorf("output is not an armored message: %q", armored)
+			}
+			if strings.Contains(armored, tt.plaintext) && tt.plaintext != "" {
+				t.Error("output contains the plaintext")
+			}
+
+			keys := openpgp.EntityList{recipient, signer}
+			plaintext, md := decryptTestMessage(t, armored, keys)
+			if plaintext != tt.plaintext {
+				t.Errorf("decrypted %q, want %q", plaintext, tt.plaintext)
+			}
+			if md.IsSigned != tt.sign {
+				t.Errorf("IsSigned = %v, want %v", md.IsSigned, tt.sign)
+			}
+			if tt.sign {
+				if md.SignatureError != nil {
+					t.Errorf("signature: %v", md.SignatureError)
+				}
+				if md.SignedBy == nil || md.SignedBy.Entity != signer {
+					t.Error("message is not signed by the signing key")
+				}
+			}
+		})
+	}
+}
+
+func TestReadPublicKeysErrors(t *testing.T) {
+	dir := t.TempDir()
+	garbage := filepath.Join(dir, "garbage.asc")
+	if err := os.WriteFile(garbage, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"unset", "", "no recipient key configured"},
+		{"missing", filepath.Join(dir, "missing.asc"), "could not read recipient key"},
+		{"garbage", garbage, "could not parse recipient key"},
+	}
+
+	for _, tt := range tests {
+		_, err := readPublicKeys(tt.path)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: readPublicKeys error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+// maxPollBackoff caps the factor by which the poll interval grows after
+// consecutive failures
+const maxPollBackoff = 8
+
+// newPollLabel creates the top bar label showing the last successful
+// poll, hidden unless polling is configured
+func (q *QuickMail) newPollLabel() *widget.Label {
+	q.pollLabel = widget.NewLabel("")
+	q.pollLabel.Importance = widget.LowImportance
+	if q.config == nil || q.config.PollMinutes <= 0 {
+		q.pollLabel.Hide()
+	}
+	return q.pollLabel
+}
+
+// startPolling downloads new messages into the maildir every poll_minutes
+// in the background and sends a desktop notification when some arrive.
+// The server handles uploads and fetches concurrently, so polling goes on
+// while a message is being sent. After consecutive failures the interval
+// doubles up to maxPollBackoff times. Polling stops when ctx is cancelled.
+func (q *QuickMail) startPolling(ctx context.Context) {
+	if q.config == nil || q.config.PollMinutes <= 0 {
+		return
+	}
+	interval := time.Duration(q.config.PollMinutes) * time.Minute
+
+	go func() {
+		backoff := 1
+
+		for {
+			messages, added, err := q.syncInbox(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				fmt.Printf("Polling for messages failed: %v\n", err)
+				backoff = min(backoff*2, maxPollBackoff)
+			} else {
+				backoff = 1
+				q.pollDone(messages, added)
+			}
+
+			select {
+			case <-time.After(interval * time.Duration(backoff)):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// pollDone updates the inbox after a successful poll and notifies about
+// the added messages
+func (q *QuickMail) pollDone(messages []InboxMessage, added int) {
+	if added > 0 {
+		text := "1 new message"
+		if added > 1 {
+			text = fmt.Sprintf("%d new messages", added)
+		}
+		q.app.SendNotification(fyne.NewNotification("QuickMail", text))
+	}
+
+	polled := time.Now().Format("15:04")
+	fyne.Do(func() {
+		q.pollLabel.SetText("Polled " + polled)
+		if added > 0 {
+			q.setInbox(messages)
+		}
+	})
+}
@@ -0,0 +1,1239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/app"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/awnumar/memguard"
+	"mime"
+)
+
+// QuickMail structure for the application
+type QuickMail struct {
+	app         fyne.App
+	window      fyne.Window
+	textArea    *messageEntry
+	config      *core.Config
+	isDarkTheme bool
+	themeMode   string
+
+	// tabs holds the Compose and Inbox tabs
+	tabs *container.AppTabs
+
+	// activeProfile is the name of the server profile sendMail targets
+	activeProfile string
+	profileSelect *widget.Select
+
+	// signMessages and encryptMessages protect the message body with PGP
+	// before it is sent
+	signMessages    bool
+	encryptMessages bool
+
+	// recipientKey names the keyring key Encrypt uses; if empty the key at
+	// recipient_key_path is used
+	recipientKey    string
+	recipientSelect *widget.Select
+
+	statusLabel *widget.Label
+	statsLabel  *widget.Label
+
+	// serverLabel and lastSendLabel show the active server and the result
+	// of the last send in the status bar
+	serverLabel   *widget.Label
+	lastSendLabel *widget.Label
+
+	// lastSendDuration is how long the last send took, successful or not
+	lastSendDuration time.Duration
+
+	// torLabel shows the result of the last Tor check and torReady
+	// enables the Send button
+	torLabel *widget.Label
+	torReady atomic.Bool
+
+	// fontSize is the text size of the message entry, applied through
+	// textOverride
+	fontSize     float32
+	textOverride *container.ThemeOverride
+
+	// wrapButton switches word wrap of the message entry and
+	// monospaceButton its font
+	wrapButton      *widget.Button
+	monospaceButton *widget.Button
+
+	// lineNumbers is the gutter left of the message entry in editor, shown
+	// with showLineNumbers while word wrap is off. editorScroll scrolls the
+	// entry while the gutter is shown.
+	editor          *fyne.Container
+	lineNumbers     *lineNumberWidget
+	editorScroll    *container.Scroll
+	showLineNumbers bool
+
+	templatesMenu *fyne.Menu
+
+	// tooltips shows the shortcuts of the buttons that have one
+	tooltips *tooltipLayer
+
+	// findWindow is the open find and replace window; findPos is where the
+	// next search starts and findMark the highlighted match
+	findWindow fyne.Window
+	findPos    int
+	findMark   findMark
+
+	// editHistory holds the undo and redo states of the text area
+	editHistory     *EditHistory
+	editTimer       *time.Timer
+	applyingHistory bool
+
+	// inbox holds the messages in the maildir and inboxSelected the index
+	// of the selected one, or -1. inboxMu keeps two syncs from downloading
+	// the same message.
+	inbox         []InboxMessage
+	inboxSelected widget.ListItemID
+	inboxMu       sync.Mutex
+	inboxList     *widget.List
+	inboxViewer   *widget.Label
+	pollLabel     *widget.Label
+
+	// sendButton shows the number of messages waiting in sendQueue or
+	// uploading. cancelButton and sendProgress are shown while a send is
+	// in flight.
+	sendButton   *widget.Button
+	cancelButton *widget.Button
+	sendProgress *widget.ProgressBar
+	sendWaiting  *widget.ProgressBarInfinite
+	sending      atomic.Bool
+	sendQueue    chan sendJob
+	sendPending  atomic.Int32
+
+	// inFlight holds the composed texts of the messages queued or
+	// uploading, so sending the same message twice is not possible
+	inFlight   map[string]bool
+	inFlightMu sync.Mutex
+
+	// cancelSend aborts the send in flight
+	cancelSend context.CancelFunc
+
+	// attachments holds the paths of the files sent along with the message
+	attachments    []string
+	attachmentList *widget.List
+	attachmentBox  *fyne.Container
+
+	// outboxButton shows the number of messages waiting in the outbox
+	outboxButton *widget.Button
+	outboxMu     sync.Mutex
+
+	// lastDraft is the text last written to draft.txt
+	lastDraft string
+	draftMu   sync.Mutex
+
+	// torProxy caches the SOCKS5 proxy found by resolveTorProxy
+	torProxy   string
+	torProxyMu sync.Mutex
+
+	// stopBackground ends inbox polling and draft autosave
+	stopBackground context.CancelFunc
+
+	// ctx ends with the window, cancelling uploads of the outbox that are
+	// still running
+	ctx      context.Context
+	closeCtx context.CancelFunc
+
+	// configMu guards replacing config, for goroutines that take a
+	// snapshot with currentConfig
+	configMu sync.Mutex
+
+	// clipboardTimer clears text copied from the message entry; it is
+	// only used on the UI thread
+	clipboardTimer *time.Timer
+}
+
+// sendMail sends the message via Tor like ocsend.go. Sending without
+// encryption needs confirmation once recipient keys have been imported.
+func (q *QuickMail) sendMail() {
+	q.composeMessage(false)
+}
+
+// addToQueue prepares the message like sendMail but adds it to the outbox
+// instead of sending it, to be sent later with Send Queue
+func (q *QuickMail) addToQueue() {
+	q.composeMessage(true)
+}
+
+// composeMessage does the work of sendMail and addToQueue
+func (q *QuickMail) composeMessage(queue bool) {
+	q.clearFindMark()
+
+	if q.config == nil {
+		q.showError("Configuration not loaded")
+		return
+	}
+
+	if !q.encryptMessages {
+		if keys, err := q.loadKeyring(); err == nil && len(keys) > 0 {
+			dialog.ShowConfirm("Send Unencrypted?",
+				"Encrypt is not enabled, so the message will be readable on the server.\nSend it unencrypted?",
+				func(send bool) {
+					if send {
+						q.unlockAndSend(queue)
+					}
+				}, q.window)
+			return
+		}
+	}
+
+	q.unlockAndSend(queue)
+}
+
+// unlockAndSend asks for the signing key passphrase if signing needs one
+// and sends or queues the message
+func (q *QuickMail) unlockAndSend(queue bool) {
+	if q.signMessages {
+		locked, err := signingKeyLocked(q.config.SigningKeyPath)
+		if err != nil {
+			q.showError(fmt.Sprintf("PGP error: %v", err))
+			return
+		}
+		if locked {
+			q.askPassphrase(func(passphrase []byte) {
+				q.prepareAndSend(passphrase, queue)
+			})
+			return
+		}
+	}
+
+	q.prepareAndSend(nil, queue)
+}
+
+// prepareAndSend applies Bcc stripping, PGP and attachments to the
+// composed message and uploads it in the background, or with queue adds it
+// to the outbox. The passphrase unlocks the signing key and is wiped
+// afterwards.
+func (q *QuickMail) prepareAndSend(passphrase []byte, queue bool) {
+	defer wipe(passphrase)
+
+	server := q.activeServer()
+	if server == nil {
+		return
+	}
+
+	original := q.textArea.Text
+	message := original
+	if strings.TrimSpace(message) == "" {
+		q.showError("Message is empty")
+		return
+	}
+
+	message, bcc, err := core.StripBcc(message)
+	if err != nil {
+		q.showError(err.Error())
+		return
+	}
+
+	if q.signMessages || q.encryptMessages {
+		// Without a header block the whole message is signed or encrypted
+		headers, body, _ := core.SplitMessage(message)
+		if strings.TrimSpace(body) == "" {
+			q.showError("Message has no body to sign or encrypt")
+			return
+		}
+
+		var recipients openpgp.EntityList
+		if q.encryptMessages {
+			recipients, err = q.recipientKeys()
+			if err != nil {
+				q.showError(fmt.Sprintf("PGP error: %v", err))
+				return
+			}
+		}
+
+		switch {
+		case q.signMessages && q.encryptMessages:
+			body, err = signAndEncryptMessage(body, recipients, q.config.SigningKeyPath, passphrase)
+		case q.encryptMessages:
+			body, err = encryptMessage(body, recipients)
+		default:
+			body, err = signMessage(body, q.config.SigningKeyPath, passphrase)
+		}
+		if err != nil {
+			q.showError(fmt.Sprintf("PGP error: %v", err))
+			return
+		}
+		message = core.JoinMessage(headers, body)
+	}
+
+	if len(q.attachments) > 0 {
+		if err := q.checkAttachmentSize(q.attachments); err != nil {
+			q.showError(fmt.Sprintf("Attachment error: %v", err))
+			return
+		}
+		message, err = buildMultipart(message, q.attachments)
+		if err != nil {
+			q.showError(fmt.Sprintf("Attachment error: %v", err))
+			return
+		}
+	}
+
+	serverURL := server.UploadURL(q.config.UseTLS)
+
+	policy := q.config.RetryPolicy()
+	serverName := server.Name
+
+	job := sendJob{original, serverName, serverURL, lockMessage(message), bcc, policy}
+	if queue {
+		q.queueJob(job)
+		return
+	}
+
+	enqueue := func(send bool) {
+		if send {
+			q.enqueueSend(job)
+		} else {
+			job.body.Destroy()
+		}
+	}
+
+	limit := q.config.MaxMessageBytes
+	tooLarge := limit > 0 && len(message) > limit
+	switch {
+	case q.config.ProxyProtocol() == core.ProxyDirect:
+		dialog.ShowConfirm("Send Without Tor?",
+			fmt.Sprintf("proxy_type is direct, so the message is sent to %s without Tor.\n"+
+				"The server and your network see your IP address. Send anyway?", server.BaseURL(q.config.UseTLS)),
+			enqueue, q.window)
+	case q.config.ShouldConfirmSend():
+		text := fmt.Sprintf("Send the message (%d bytes) to %s?", len(message), server.BaseURL(q.config.UseTLS))
+		if tooLarge {
+			text += fmt.Sprintf("\n\nThe message is more than the limit of %d bytes.\nThe server may reject it.", limit)
+		}
+		dialog.ShowConfirm("Send Message?", text, enqueue, q.window)
+	case tooLarge:
+		dialog.ShowConfirm("Message Too Large",
+			fmt.Sprintf("The message is %d bytes, more than the limit of %d bytes.\nThe server may reject it. Send anyway?", len(message), limit),
+			enqueue, q.window)
+	default:
+		enqueue(true)
+	}
+}
+
+// runSendJob uploads a queued message and reports the result; failed sends
+// that may succeed later are moved to the outbox. It runs on the send
+// worker, one job at a time.
+func (q *QuickMail) runSendJob(job sendJob) {
+	defer job.body.Destroy()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fyne.DoAndWait(func() {
+		q.cancelSend = cancel
+	})
+	q.sending.Store(true)
+	q.setSending(true)
+
+	startTime := time.Now()
+	err := q.uploadMessage(ctx, job.url, job.body.Bytes(), job.bcc, job.policy)
+	elapsed := time.Since(startTime)
+	q.sending.Store(false)
+	q.setSending(false)
+
+	if errors.Is(err, context.Canceled) {
+		q.setLastSend(elapsed, "cancelled", widget.WarningImportance)
+		q.setStatus("Send cancelled")
+		q.showInfo("Send cancelled", "The message was not sent.")
+		return
+	}
+
+	queued := ""
+	failed := "failed"
+	if err != nil && core.IsTransient(err) {
+		queueErr := q.queueMessage(OutboxEntry{
+			Server:    job.serverName,
+			ServerURL: job.url,
+			Message:   string(job.body.Bytes()),
+			Bcc:       job.bcc,
+			Queued:    time.Now(),
+		})
+		if queueErr != nil {
+			queued = fmt.Sprintf("\n\nThe message could not be queued: %v", queueErr)
+		} else {
+			queued = "\n\nThe message was queued in the outbox."
+			failed += ", queued"
+		}
+	}
+
+	if err == nil {
+		q.setLastSend(elapsed, "sent", widget.SuccessImportance)
+	} else {
+		q.setLastSend(elapsed, failed, widget.DangerImportance)
+	}
+
+	var proxyErr *core.ProxyError
+	if errors.As(err, &proxyErr) {
+		q.showError(fmt.Sprintf("Tor doesn't appear to be running.\n"+
+			"No SOCKS5 proxy answered at %s.\n\n"+
+			"Start the Tor daemon or Tor Browser, or check tor_proxy in the settings.%s", proxyErr.Address, queued))
+	} else if err != nil {
+		q.showError(fmt.Sprintf("Send error: %v%s", err, queued))
+	} else {
+		q.deleteDraft(job.original)
+		fyne.Do(func() {
+			// The next message may already be composed
+			if q.textArea.Text == job.original {
+				q.resetEditHistory()
+			}
+		})
+		q.showSuccess(fmt.Sprintf("Message sent successfully!\n%s", q.routeDescription()))
+	}
+}
+
+// uploadMessage uploads the message via Tor, retrying failed attempts with
+// exponential backoff according to policy. The bcc addresses are passed to
+// the server in the core.BccHeader request header. message is read in
+// place, so it should be the bytes of a LockedBuffer.
+func (q *QuickMail) uploadMessage(ctx context.Context, serverURL string, message []byte, bcc []string, policy core.RetryPolicy) error {
+	config := q.currentConfig()
+	client, err := q.sendClient(config)
+	if err != nil {
+		return err
+	}
+	return q.uploadMessageWith(ctx, client, config, serverURL, message, bcc, policy)
+}
+
+// uploadMessageWith does the work of uploadMessage with client, which
+// several uploads may share to use the same Tor circuit, and the send
+// timeout of config
+func (q *QuickMail) uploadMessageWith(ctx context.Context, client *http.Client, config *core.Config, serverURL string, message []byte, bcc []string, policy core.RetryPolicy) error {
+	startTime := time.Now()
+	client.Timeout = config.SendTimeout(len(message))
+
+	err := core.Upload(ctx, client, serverURL, message, core.UploadOptions{
+		Bcc:      bcc,
+		Policy:   policy,
+		Status:   q.setStatus,
+		Progress: q.setProgress,
+	})
+	if err != nil {
+		return err
+	}
+
+	elapsedTime := time.Since(startTime)
+	q.setStatus(fmt.Sprintf("Message sent, elapsed time %s", q.formatDuration(elapsedTime)))
+	q.recordSent(serverURL, message, elapsedTime)
+
+	return nil
+}
+
+func (q *QuickMail) formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	s := d / time.Second
+
+	return fmt.Sprintf("%02d:%02d:%02d", h, m, s)
+}
+
+// clearContent safely clears the text area and clipboard
+func (q *QuickMail) clearContent() {
+	q.textArea.SetText("")
+	q.resetEditHistory()
+	q.deleteDraft("")
+	q.attachments = nil
+	q.refreshAttachments()
+	if q.window.Clipboard() != nil {
+		q.window.Clipboard().SetContent("")
+	}
+}
+
+// confirmClear clears the message, asking first if the text area is not
+// empty and confirm_clear is not disabled
+func (q *QuickMail) confirmClear() {
+	if q.textArea.Text == "" || !q.config.ShouldConfirmClear() {
+		q.clearContent()
+		return
+	}
+
+	dialog.ShowConfirm("Clear Message", "Discard current message?", func(discard bool) {
+		if discard {
+			q.clearContent()
+		}
+	}, q.window)
+}
+
+// toggleTheme switches between dark and light theme and remembers the
+// choice for the next start, in the config if it sets a theme
+func (q *QuickMail) toggleTheme() {
+	mode := core.ThemeDark
+	if q.isDarkTheme {
+		mode = core.ThemeLight
+	}
+	q.applyTheme(mode)
+
+	if q.config.ThemeMode() != "" {
+		q.config.Theme = mode
+		if err := core.SaveConfig(q.config); err != nil {
+			fmt.Printf("Warning: Could not save config: %v\n", err)
+		}
+	} else {
+		newThemePreference(q.app, q.config).setDark(q.isDarkTheme)
+	}
+	q.window.Content().Refresh()
+}
+
+// setStatus shows text in the status line below the buttons; it may be
+// called from any goroutine
+func (q *QuickMail) setStatus(text string) {
+	if q.statusLabel == nil {
+		return
+	}
+	fyne.Do(func() {
+		q.statusLabel.SetText(text)
+	})
+}
+
+// setSending shows the Cancel button and a spinner while a send is in
+// flight; the spinner gives way to the progress bar once data is being
+// uploaded. It may be called from any goroutine.
+func (q *QuickMail) setSending(sending bool) {
+	if q.cancelButton == nil {
+		return
+	}
+	fyne.Do(func() {
+		if sending {
+			q.cancelButton.Show()
+			q.sendProgress.SetValue(0)
+			q.sendWaiting.Show()
+			q.sendWaiting.Start()
+		} else {
+			q.cancelButton.Hide()
+			q.sendProgress.Hide()
+			q.sendProgress.SetValue(0)
+			q.sendWaiting.Stop()
+			q.sendWaiting.Hide()
+			q.cancelSend = nil
+		}
+	})
+}
+
+// cancelSending cancels the send in flight; queued messages are still sent
+func (q *QuickMail) cancelSending() {
+	if q.cancelSend != nil {
+		q.cancelSend()
+	}
+}
+
+// sendIfReady starts a send unless Tor is not reachable or the message is
+// already being sent. While another message is uploading the new one waits
+// in the send queue.
+func (q *QuickMail) sendIfReady() {
+	if q.isInFlight(q.textArea.Text) {
+		return
+	}
+	if !q.torReady.Load() {
+		q.setStatus("Tor is not reachable, check the connection first")
+		return
+	}
+	q.sendMail()
+}
+
+// setProgress shows how many bytes of the message have been uploaded.
+// Once everything is sent a spinner is shown while waiting for the server,
+// which delays its answer on purpose. It may be called from any goroutine.
+func (q *QuickMail) setProgress(sent, total int64) {
+	if q.sendProgress == nil || total == 0 || !q.sending.Load() {
+		return
+	}
+	fyne.Do(func() {
+		if sent >= total {
+			q.sendProgress.Hide()
+			q.sendWaiting.Show()
+			q.sendWaiting.Start()
+			return
+		}
+
+		q.sendWaiting.Stop()
+		q.sendWaiting.Hide()
+		q.sendProgress.TextFormatter = func() string {
+			return fmt.Sprintf("%d / %d bytes", sent, total)
+		}
+		q.sendProgress.SetValue(float64(sent) / float64(total))
+		q.sendProgress.Show()
+	})
+}
+
+// notify shows an information dialog on the Fyne main thread, so
+// background work like sending and flushing the outbox can report results
+// without touching the UI directly; it may be called from any goroutine
+func (q *QuickMail) notify(title, message string) {
+	fyne.Do(func() {
+		dialog.ShowInformation(title, message, q.window)
+	})
+}
+
+// showError shows an error dialog; it may be called from any goroutine
+func (q *QuickMail) showError(message string) {
+	q.notify("Error", message)
+}
+
+// showInfo shows a neutral information dialog; it may be called from any
+// goroutine
+func (q *QuickMail) showInfo(title, message string) {
+	q.notify(title, message)
+}
+
+// showSuccess shows a success dialog; it may be called from any goroutine
+func (q *QuickMail) showSuccess(message string) {
+	q.notify("Success", message)
+}
+
+// currentConfig returns the config in use. Goroutines take it once, before
+// they start, and work with that snapshot even if the settings change.
+func (q *QuickMail) currentConfig() *core.Config {
+	q.configMu.Lock()
+	defer q.configMu.Unlock()
+	return q.config
+}
+
+// activeServer returns the selected server profile, or shows an error and
+// returns nil if there is none or its address is not set yet
+func (q *QuickMail) activeServer() *core.ServerProfile {
+	if q.config == nil {
+		q.showError("Configuration not loaded")
+		return nil
+	}
+	server := q.config.Profile(q.activeProfile)
+	if server == nil {
+		q.showError("No server profile selected")
+		return nil
+	}
+	if err := server.CheckAddress(); err != nil {
+		q.showError(fmt.Sprintf("Server profile %s has no address yet.\nEnter it in Settings.", server.Name))
+		return nil
+	}
+	return server
+}
+
+// selectProfile makes the named profile the send target and remembers it
+// in the config as the last used profile
+func (q *QuickMail) selectProfile(name string) {
+	q.activeProfile = name
+	q.updateServerLabel()
+	if q.config == nil || q.config.ActiveServer == name {
+		return
+	}
+
+	q.config.ActiveServer = name
+	if err := core.SaveConfig(q.config); err != nil {
+		fmt.Printf("Warning: Could not remember active server: %v\n", err)
+	}
+}
+
+// refreshProfiles reloads the profile selector from the config and selects
+// the named profile
+func (q *QuickMail) refreshProfiles(selected string) {
+	q.activeProfile = selected
+	if q.profileSelect == nil {
+		return
+	}
+
+	if q.config != nil {
+		q.profileSelect.Options = q.config.ProfileNames()
+	} else {
+		q.profileSelect.Options = nil
+	}
+	q.profileSelect.SetSelected(selected)
+	q.profileSelect.Refresh()
+	q.updateServerLabel()
+}
+
+// startBackground starts inbox polling and draft autosave with the current
+// config, stopping those started before
+func (q *QuickMail) startBackground() {
+	if q.stopBackground != nil {
+		q.stopBackground()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q.stopBackground = cancel
+	q.startPolling(ctx)
+	q.startDraftAutosave(ctx)
+}
+
+// cursorOffset maps a cursor row and column, where the column counts
+// runes as widget.Entry does, to a byte offset into text. Positions beyond
+// the end of a line or of the text are clamped.
+func cursorOffset(text string, row, col int) int {
+	offset := 0
+	for i := 0; i < row; i++ {
+		newline := strings.IndexByte(text[offset:], '\n')
+		if newline < 0 {
+			return len(text)
+		}
+		offset += newline + 1
+	}
+
+	for col > 0 && offset < len(text) && text[offset] != '\n' {
+		_, size := utf8.DecodeRuneInString(text[offset:])
+		offset += size
+		col--
+	}
+	return offset
+}
+
+// cursorPosition is the inverse of cursorOffset and returns the row and
+// rune column of a byte offset into text
+func cursorPosition(text string, offset int) (row, col int) {
+	before := text[:offset]
+	row = strings.Count(before, "\n")
+	col = utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:])
+	return row, col
+}
+
+// insertAtCursor inserts text at the cursor of the text area and moves the
+// cursor to the end of the inserted text
+func (q *QuickMail) insertAtCursor(text string) {
+	currentText := q.textArea.Text
+	offset := cursorOffset(currentText, q.textArea.CursorRow, q.textArea.CursorColumn)
+
+	newText := currentText[:offset] + text + currentText[offset:]
+	q.textArea.SetText(newText)
+
+	q.textArea.CursorRow, q.textArea.CursorColumn = cursorPosition(newText, offset+len(text))
+	q.textArea.Refresh()
+}
+
+// showSubjectDialog shows a dialog to enter the subject and encodes it
+func (q *QuickMail) showSubjectDialog() {
+	subjectEntry := widget.NewEntry()
+	subjectEntry.PlaceHolder = "Enter subject here..."
+
+	encodings := []string{"Base64 (B)", "Quoted-printable (Q)"}
+	encodingRadio := widget.NewRadioGroup(encodings, nil)
+	encodingRadio.Horizontal = true
+	encodingRadio.Required = true
+	encodingRadio.SetSelected(encodings[0])
+	if q.config.WordEncoder() == mime.QEncoding {
+		encodingRadio.SetSelected(encodings[1])
+	}
+
+	subjectDialog := dialog.NewForm(
+		"Enter Subject",
+		"Encode",
+		"Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("Subject:", subjectEntry),
+			widget.NewFormItem("Encoding:", encodingRadio),
+		},
+		func(confirmed bool) {
+			if confirmed && subjectEntry.Text != "" {
+				encoding := mime.BEncoding
+				if encodingRadio.Selected == encodings[1] {
+					encoding = mime.QEncoding
+				}
+				encodedSubject := core.EncodeMIMESubject(subjectEntry.Text, encoding) + "\n"
+				q.insertAtCursor(encodedSubject)
+			}
+		},
+		q.window,
+	)
+
+	subjectDialog.Show()
+	subjectDialog.Resize(fyne.NewSize(460, 200))
+}
+
+// showHeaderDialog shows a form for From, To, Cc, Bcc and Subject and
+// inserts the encoded header block at the top of the message
+func (q *QuickMail) showHeaderDialog() {
+	fromEntry := widget.NewEntry()
+	fromEntry.PlaceHolder = "Optional, the server sets its own From:"
+	if q.config != nil {
+		fromEntry.SetText(q.config.LastFrom)
+	}
+
+	toEntry := widget.NewEntry()
+	toEntry.PlaceHolder = "Name <recipient@example.com>"
+
+	ccEntry := widget.NewEntry()
+	ccEntry.PlaceHolder = "Optional, comma-separated"
+
+	bccEntry := widget.NewEntry()
+	bccEntry.PlaceHolder = "Optional, comma-separated, not shown to recipients"
+
+	subjectEntry := widget.NewEntry()
+	subjectEntry.PlaceHolder = "Enter subject here..."
+
+	newsgroupsEntry := widget.NewEntry()
+	newsgroupsEntry.PlaceHolder = "Optional, e.g. alt.privacy.anon-server"
+
+	headerDialog := dialog.NewForm(
+		"Enter Headers",
+		"Insert",
+		"Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("From:", fromEntry),
+			widget.NewFormItem("To:", toEntry),
+			widget.NewFormItem("Cc:", ccEntry),
+			widget.NewFormItem("Bcc:", bccEntry),
+			widget.NewFormItem("Subject:", subjectEntry),
+			widget.NewFormItem("Newsgroups:", newsgroupsEntry),
+		},
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+
+			newsgroups, err := formatNewsgroups(newsgroupsEntry.Text)
+			if err != nil {
+				q.showError(fmt.Sprintf("Invalid Newsgroups: %v", err))
+				return
+			}
+
+			// The server delivers by To:, so posts to newsgroups still
+			// need the address of a mail2news gateway there
+			if !strings.Contains(toEntry.Text, "@") {
+				q.showError("To: must contain at least one email address")
+				return
+			}
+
+			var block strings.Builder
+
+			from := strings.TrimSpace(fromEntry.Text)
+			if from != "" {
+				encoded, err := core.EncodeAddressList(from)
+				if err != nil {
+					q.showError(fmt.Sprintf("Invalid From: address: %v", err))
+					return
+				}
+				block.WriteString("From: " + encoded + "\n")
+			}
+
+			to, err := core.EncodeAddressList(strings.TrimSpace(toEntry.Text))
+			if err != nil {
+				q.showError(fmt.Sprintf("Invalid To: address: %v", err))
+				return
+			}
+			block.WriteString("To: " + to + "\n")
+
+			if cc := strings.TrimSpace(ccEntry.Text); cc != "" {
+				encoded, err := core.EncodeAddressList(cc)
+				if err != nil {
+					q.showError(fmt.Sprintf("Invalid Cc: address: %v", err))
+					return
+				}
+				block.WriteString("Cc: " + encoded + "\n")
+			}
+
+			if bcc := strings.TrimSpace(bccEntry.Text); bcc != "" {
+				encoded, err := core.EncodeAddressList(bcc)
+				if err != nil {
+					q.showError(fmt.Sprintf("Invalid Bcc: address: %v", err))
+					return
+				}
+				block.WriteString("Bcc: " + encoded + "\n")
+			}
+
+			if subject := strings.TrimSpace(subjectEntry.Text); subject != "" {
+				block.WriteString("Subject: " + core.EncodeMIMESubject(subject, q.config.WordEncoder()) + "\n")
+			}
+
+			if newsgroups != "" {
+				block.WriteString("Newsgroups: " + newsgroups + "\n")
+			}
+
+			q.textArea.SetText(block.String() + "\n" + q.textArea.Text)
+			q.rememberFrom(from)
+		},
+		q.window,
+	)
+
+	headerDialog.Show()
+	headerDialog.Resize(fyne.NewSize(460, 400))
+}
+
+// formatNewsgroups validates a comma-separated list of newsgroup names and
+// returns it in the form used by the Newsgroups: header
+func formatNewsgroups(input string) (string, error) {
+	if strings.TrimSpace(input) == "" {
+		return "", nil
+	}
+
+	var groups []string
+	for _, group := range strings.Split(input, ",") {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			continue
+		}
+		if strings.ContainsFunc(group, func(r rune) bool { return r <= ' ' || r > '~' }) {
+			return "", fmt.Errorf("%q is not a valid newsgroup name", group)
+		}
+		groups = append(groups, group)
+	}
+	return strings.Join(groups, ","), nil
+}
+
+// rememberFrom saves the From: address last used in the header dialog to
+// the config file
+func (q *QuickMail) rememberFrom(from string) {
+	if q.config == nil || q.config.LastFrom == from {
+		return
+	}
+
+	q.config.LastFrom = from
+	if err := core.SaveConfig(q.config); err != nil {
+		fmt.Printf("Warning: Could not save config: %v\n", err)
+	}
+}
+
+func main() {
+	// Wipe locked buffers when the process is interrupted
+	memguard.CatchInterrupt()
+
+	sendFlag := flag.Bool("send", false, "send a message without opening a window")
+	var file string
+	flag.StringVar(&file, "file", "", "with -send, read the message from this file instead of stdin")
+	flag.StringVar(&file, "f", "", "shorthand for -file")
+	var overrides cliOverrides
+	flag.StringVar(&overrides.onionAddress, "onion", "", "with -send, the server address instead of the configured one")
+	flag.StringVar(&overrides.port, "port", "", "with -send, the server port instead of the configured one")
+	flag.StringVar(&overrides.torProxy, "proxy", "", "with -send, the Tor proxy instead of tor_proxy")
+	flag.Parse()
+
+	if *sendFlag {
+		os.Exit(sendHeadless(file, overrides))
+	}
+
+	myApp := app.NewWithID(appID)
+	window := myApp.NewWindow("Quick Mail")
+
+	// Load configuration
+	config, configErr := core.LoadConfig()
+	firstRun := false
+	if errors.Is(configErr, core.ErrNoConfig) {
+		config, configErr = core.CreateDefaultConfig()
+		firstRun = configErr == nil
+	}
+	if configErr != nil {
+		fmt.Printf("Warning: Could not load config: %v\n", configErr)
+	} else {
+		fmt.Printf("Loaded config from %s\n", config.Path())
+		for _, warning := range config.Warnings() {
+			fmt.Printf("Warning: %s\n", warning)
+		}
+	}
+
+	// Create QuickMail instance
+	quickMail := &QuickMail{
+		app:      myApp,
+		window:   window,
+		config:   config,
+		fontSize: configFontSize(config),
+	}
+	quickMail.ctx, quickMail.closeCtx = context.WithCancel(context.Background())
+
+	// Set initial theme
+	quickMail.applyTheme(newThemePreference(myApp, config).mode())
+	quickMail.followSystemTheme()
+
+	// Create text area with mono font
+	textArea := newMessageEntry()
+	textArea.TextStyle = fyne.TextStyle{Monospace: true}
+	textArea.MultiLine = true
+	textArea.PlaceHolder = "Enter your message here..."
+	textArea.OnChanged = func(text string) {
+		quickMail.updateStats(text)
+		quickMail.recordEdit()
+		quickMail.updateSendEnabled()
+		quickMail.textChangedLineNumbers(text)
+	}
+	textArea.OnCursorChanged = quickMail.scrollToCursor
+	textArea.onCopy = quickMail.clipboardCopied
+
+	quickMail.textArea = textArea
+	quickMail.newEditHistory()
+
+	// Create server profile selector
+	profileSelect := widget.NewSelect(nil, quickMail.selectProfile)
+	profileSelect.PlaceHolder = "Select server"
+	quickMail.profileSelect = profileSelect
+	if config != nil {
+		quickMail.refreshProfiles(config.InitialProfile())
+	}
+
+	// Buttons with shortcuts show them in tooltips drawn on this layer
+	quickMail.tooltips = newTooltipLayer()
+
+	// Create theme switch button
+	themeSwitch := newTipButton("", theme.ViewRefreshIcon(), quickMail.shortcutTip("Toggle Theme"), quickMail.tooltips, quickMail.toggleTheme)
+	themeSwitch.Importance = widget.LowImportance
+
+	// Create settings button
+	settingsButton := widget.NewButtonWithIcon("", theme.SettingsIcon(), quickMail.showSettingsDialog)
+	settingsButton.Importance = widget.LowImportance
+
+	// Create outbox button
+	outboxButton := widget.NewButtonWithIcon("Outbox (0)", theme.MailSendIcon(), quickMail.showOutboxDialog)
+	outboxButton.Importance = widget.LowImportance
+	quickMail.outboxButton = outboxButton
+
+	// Create history button
+	historyButton := widget.NewButtonWithIcon("History", theme.HistoryIcon(), quickMail.showHistoryDialog)
+	historyButton.Importance = widget.LowImportance
+
+	// Create font size buttons
+	zoomOutButton := newTipButton("", theme.ZoomOutIcon(), quickMail.shortcutTip("Smaller Font"), quickMail.tooltips, quickMail.decreaseFontSize)
+	zoomOutButton.Importance = widget.LowImportance
+	zoomInButton := newTipButton("", theme.ZoomInIcon(), quickMail.shortcutTip("Larger Font"), quickMail.tooltips, quickMail.increaseFontSize)
+	zoomInButton.Importance = widget.LowImportance
+
+	// Create top bar
+	topBar := container.NewHBox(
+		profileSelect,
+		quickMail.newPollLabel(),
+		layout.NewSpacer(),
+		zoomOutButton,
+		zoomInButton,
+		quickMail.newWrapButton(),
+		quickMail.newMonospaceButton(),
+		historyButton,
+		outboxButton,
+		settingsButton,
+		themeSwitch,
+	)
+
+	// Create centered buttons
+	mimeButton := newTipButton("MIME", nil, quickMail.shortcutTip("MIME Subject..."), quickMail.tooltips, func() {
+		quickMail.showSubjectDialog()
+	})
+
+	headersButton := widget.NewButton("Headers", func() {
+		quickMail.showHeaderDialog()
+	})
+
+	attachButton := widget.NewButton("Attach", func() {
+		quickMail.showAttachDialog()
+	})
+
+	openButton := newTipButton("Open", theme.FolderOpenIcon(), quickMail.shortcutTip("Open..."), quickMail.tooltips, quickMail.showOpenFileDialog)
+	saveButton := newTipButton("Save", theme.DocumentSaveIcon(), quickMail.shortcutTip("Save..."), quickMail.tooltips, quickMail.showSaveFileDialog)
+
+	importButton := widget.NewButton("Import .eml", func() {
+		quickMail.showImportEMLDialog()
+	})
+
+	exportButton := widget.NewButton("Export .eml", func() {
+		quickMail.showExportEMLDialog()
+	})
+
+	sendButton := newTipButton("Send", nil, quickMail.shortcutTip("Send"), quickMail.tooltips, func() {
+		quickMail.sendIfReady()
+	})
+	sendButton.Disable()
+	quickMail.sendButton = &sendButton.Button
+
+	cancelButton := widget.NewButtonWithIcon("Cancel", theme.CancelIcon(), quickMail.cancelSending)
+	cancelButton.Hide()
+	quickMail.cancelButton = cancelButton
+
+	signCheck := widget.NewCheck("Sign", func(checked bool) {
+		quickMail.signMessages = checked
+	})
+
+	var encryptCheck *widget.Check
+	encryptCheck = widget.NewCheck("Encrypt", func(checked bool) {
+		if checked {
+			if err := quickMail.checkRecipientKey(); err != nil {
+				quickMail.showError(fmt.Sprintf("Encryption not available: %v", err))
+				encryptCheck.SetChecked(false)
+				return
+			}
+		}
+		quickMail.encryptMessages = checked
+	})
+
+	clearButton := newTipButton("Clear", nil, quickMail.shortcutTip("Clear"), quickMail.tooltips, func() {
+		quickMail.confirmClear()
+	})
+
+	// Center the buttons
+	buttons := container.NewHBox(
+		layout.NewSpacer(),
+		openButton,
+		saveButton,
+		mimeButton,
+		headersButton,
+		attachButton,
+		importButton,
+		exportButton,
+		signCheck,
+		encryptCheck,
+		quickMail.newRecipientSelect(),
+		sendButton,
+		cancelButton,
+		clearButton,
+		layout.NewSpacer(),
+	)
+
+	// Create status line and send progress bar
+	statusLabel := widget.NewLabel("")
+	statusLabel.Alignment = fyne.TextAlignCenter
+	quickMail.statusLabel = statusLabel
+
+	sendProgress := widget.NewProgressBar()
+	sendProgress.Hide()
+	quickMail.sendProgress = sendProgress
+
+	sendWaiting := widget.NewProgressBarInfinite()
+	sendWaiting.Stop()
+	sendWaiting.Hide()
+	quickMail.sendWaiting = sendWaiting
+
+	// The Compose tab must stay first, Reply switches to it by index
+	quickMail.tabs = container.NewAppTabs(
+		container.NewTabItem("Compose", container.NewBorder(
+			nil,
+			quickMail.newAttachmentList(),
+			nil,
+			nil,
+			quickMail.newTextAreaOverride(quickMail.newEditor()),
+		)),
+		container.NewTabItem("Inbox", quickMail.newInboxTab()),
+	)
+
+	// Create main content
+	content := container.NewBorder(
+		container.NewVBox(
+			topBar,
+			widget.NewSeparator(),
+		),
+		container.NewVBox(
+			container.NewStack(sendProgress, sendWaiting),
+			buttons,
+			statusLabel,
+			container.NewHBox(
+				quickMail.newTorLabel(),
+				widget.NewButtonWithIcon("Check Tor", theme.MediaReplayIcon(), quickMail.checkTor),
+				widget.NewButtonWithIcon("Test Connection", theme.ConfirmIcon(), quickMail.testConnection),
+				widget.NewSeparator(),
+				quickMail.newServerLabel(),
+				widget.NewSeparator(),
+				quickMail.newLastSendLabel(),
+				layout.NewSpacer(),
+				quickMail.newStatsLabel(),
+			),
+		),
+		nil,
+		nil,
+		quickMail.tabs,
+	)
+
+	// Create main menu. Quit closes the window so drafts are saved as on
+	// any other close.
+	quitItem := fyne.NewMenuItem("Quit", quickMail.closeWindow)
+	quitItem.IsQuit = true
+	window.SetMainMenu(fyne.NewMainMenu(
+		fyne.NewMenu("File", append(quickMail.registerShortcuts("File"),
+			fyne.NewMenuItem("Import .eml...", quickMail.showImportEMLDialog),
+			fyne.NewMenuItem("Export .eml...", quickMail.showExportEMLDialog),
+			fyne.NewMenuItem("Batch Send...", quickMail.showBatchSendDialog),
+			fyne.NewMenuItemSeparator(),
+			fyne.NewMenuItem("Settings...", quickMail.showSettingsDialog),
+			fyne.NewMenuItem("Recipient Keys...", quickMail.showKeyringDialog),
+			fyne.NewMenuItem("Import Signing Key...", quickMail.showImportSigningKeyDialog),
+			fyne.NewMenuItemSeparator(),
+			quitItem,
+		)...),
+		fyne.NewMenu("Edit", append(quickMail.registerShortcuts("Edit"),
+			fyne.NewMenuItemSeparator(),
+			fyne.NewMenuItem("Copy", quickMail.copyText),
+			fyne.NewMenuItem("Paste", quickMail.pasteText),
+		)...),
+		fyne.NewMenu("View", quickMail.registerShortcuts("View")...),
+		fyne.NewMenu("Message", append(quickMail.registerShortcuts("Message"),
+			fyne.NewMenuItemSeparator(),
+			fyne.NewMenuItem("Add to Queue", quickMail.addToQueue),
+			fyne.NewMenuItem("Send Queue...", quickMail.showOutboxDialog),
+			fyne.NewMenuItemSeparator(),
+			fyne.NewMenuItem("Encrypt (age)", quickMail.encryptAge),
+			fyne.NewMenuItem("Decrypt (age)", quickMail.decryptAge),
+		)...),
+		quickMail.newTemplatesMenu(),
+		fyne.NewMenu("History",
+			fyne.NewMenuItem("Sent Messages...", quickMail.showHistoryDialog),
+		),
+		fyne.NewMenu("Help",
+			fyne.NewMenuItem("Keyboard Shortcuts", quickMail.showShortcutsDialog),
+		),
+	))
+
+	window.SetContent(container.NewStack(content, quickMail.tooltips.container))
+
+	// Load text files dropped on the window into the message
+	window.SetOnDropped(quickMail.loadDroppedFile)
+	quickMail.restoreWindowSize()
+
+	// Start with the message of a clicked mailto: link
+	if uri := flag.Arg(0); uri != "" {
+		to, subject, body, err := parseMailto(uri)
+		if err != nil {
+			fmt.Printf("Warning: Could not open %s: %v\n", uri, err)
+		} else {
+			textArea.SetText(mailtoStub(to, subject, body, config.WordEncoder()))
+		}
+	}
+
+	// Explain why sending will not work until the config is fixed, or ask
+	// for the server of a newly created config
+	if configErr != nil {
+		dialog.ShowInformation("Configuration Error",
+			fmt.Sprintf("Could not load the configuration:\n%v\n\nFix quickmail.json and restart QuickMail to send messages.", configErr), window)
+	} else if firstRun {
+		quickMail.setStatus("Created " + config.Path() + ", enter your server to get started")
+		quickMail.showSettingsDialog()
+	}
+
+	// Offer to restore an unsent draft and keep saving the current one
+	quickMail.offerDraftRestore()
+	window.SetCloseIntercept(quickMail.closeWindow)
+
+	// Enable Send once Tor is reachable
+	quickMail.checkTor()
+
+	// Poll for new messages and autosave drafts until the window closes,
+	// then remember its size
+	window.SetOnClosed(func() {
+		quickMail.stopBackground()
+		quickMail.closeCtx()
+		quickMail.saveWindowSize()
+	})
+	quickMail.startBackground()
+
+	// Upload sent messages one at a time
+	quickMail.startSendWorker()
+
+	// Retry messages left in the outbox by an earlier session
+	outboxConfig := quickMail.currentConfig()
+	go func() {
+		quickMail.refreshOutbox()
+		quickMail.flushOutbox(quickMail.ctx, outboxConfig, false, nil)
+	}()
+
+	window.ShowAndRun()
+}
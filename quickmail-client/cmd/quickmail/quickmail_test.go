@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+
+	"fyne.io/fyne/v2/test"
+)
+
+func TestCursorOffset(t *testing.T) {
+	const text = "Grüße\n🔒 sealed\n\nend"
+
+	tests := []struct {
+		name     string
+		row, col int
+		offset   int
+	}{
+		{"start", 0, 0, 0},
+		{"after two-byte rune", 0, 3, 4},
+		{"end of first line", 0, 5, 7},
+		{"column past line end stops at newline", 0, 40, 7},
+		{"after four-byte rune", 1, 1, 12},
+		{"second line", 1, 3, 14},
+		{"empty line", 2, 0, 20},
+		{"empty line past end", 2, 5, 20},
+		{"last line", 3, 3, 24},
+		{"row past end", 9, 0, 24},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cursorOffset(text, tt.row, tt.col); got != tt.offset {
+				t.Errorf("cursorOffset(%d, %d) = %d, want %d", tt.row, tt.col, got, tt.offset)
+			}
+		})
+	}
+}
+
+func TestCursorPositionInvertsOffset(t *testing.T) {
+	texts := []string{
+		"",
+		"plain ascii",
+		"Grüße\n🔒 sealed\n\nend",
+		"日本語\n\nтекст\n",
+	}
+
+	for _, text := range texts {
+		// Every rune boundary, and the end of the text, must map to a
+		// cursor position and back
+		offsets := []int{len(text)}
+		for offset := range text {
+			offsets = append(offsets, offset)
+		}
+		for _, offset := range offsets {
+			row, col := cursorPosition(text, offset)
+			if got := cursorOffset(text, row, col); got != offset {
+				t.Errorf("%q: offset %d -> (%d, %d) -> %d", text, offset, row, col, got)
+			}
+		}
+	}
+}
+
+func TestInsertAtCursor(t *testing.T) {
+	test.NewTempApp(t)
+
+	tests := []struct {
+		name             string
+		text             string
+		row, col         int
+		insert           string
+		want             string
+		wantRow, wantCol int
+	}{
+		{"after umlauts", "Grüße!", 0, 5, ", Welt", "Grüße, Welt!", 0, 11},
+		{"after emoji", "🔒🔒", 0, 1, "ü", "🔒ü🔒", 0, 2},
+		{"multi-line insert", "To: a\nend", 1, 0, "Subject: Ü\n\n", "To: a\nSubject: Ü\n\nend", 3, 0},
+		{"subject header", "ß\n", 1, 0, "Subject: =?UTF-8?B?w58=?=\n", "ß\nSubject: =?UTF-8?B?w58=?=\n", 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &QuickMail{textArea: newMessageEntry()}
+			q.textArea.SetText(tt.text)
+			q.textArea.CursorRow, q.textArea.CursorColumn = tt.row, tt.col
+
+			q.insertAtCursor(tt.insert)
+
+			if q.textArea.Text != tt.want {
+				t.Errorf("text = %q, want %q", q.textArea.Text, tt.want)
+			}
+			if q.textArea.CursorRow != tt.wantRow || q.textArea.CursorColumn != tt.wantCol {
+				t.Errorf("cursor = (%d, %d), want (%d, %d)",
+					q.textArea.CursorRow, q.textArea.CursorColumn, tt.wantRow, tt.wantCol)
+			}
+		})
+	}
+}
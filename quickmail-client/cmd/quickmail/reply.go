@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2/dialog"
+)
+
+// quoteWidth is the length quoted lines are wrapped at, including "> "
+const quoteWidth = 72
+
+// wrapLine splits line into pieces of at most width runes, breaking after
+// the last space that fits or, in a word longer than width, in the word
+func wrapLine(line string, width int) []string {
+	var lines []string
+	for utf8.RuneCountInString(line) > width {
+		cut := len(line)
+		runes := 0
+		lastSpace := -1
+		for i, r := range line {
+			if runes == width {
+				cut = i
+				break
+			}
+			if r == ' ' {
+				lastSpace = i
+			}
+			runes++
+		}
+		if lastSpace > 0 {
+			cut = lastSpace + 1
+		}
+		lines = append(lines, strings.TrimRight(line[:cut], " "))
+		line = line[cut:]
+	}
+	return append(lines, line)
+}
+
+// quoteText prefixes every line of text with "> ", wrapping lines longer
+// than quoteWidth
+func quoteText(text string) string {
+	var quoted strings.Builder
+	text = strings.TrimRight(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, ">") {
+			// Already quoted lines are not rewrapped
+			quoted.WriteString(">" + line + "\n")
+			continue
+		}
+		for _, part := range wrapLine(line, quoteWidth-2) {
+			quoted.WriteString(strings.TrimRight("> "+part, " ") + "\n")
+		}
+	}
+	return quoted.String()
+}
+
+// replyText returns a reply to the raw message: headers addressed to its
+// sender with a "Re:" subject and In-Reply-To, an empty line to type in,
+// and the quoted body. The body is the plain text part, decoded like with
+// Import .eml. It also returns the row of the empty line.
+func replyText(raw string, encoding mime.WordEncoder) (string, int, error) {
+	message, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		return "", 0, fmt.Errorf("could not parse message: %w", err)
+	}
+
+	var headers []string
+	to := message.Header.Get("Reply-To")
+	if to == "" {
+		to = message.Header.Get("From")
+	}
+	if to != "" {
+		headers = append(headers, "To: "+to)
+	}
+
+	subject := message.Header.Get("Subject")
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(subject); err == nil {
+		subject = decoded
+	}
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = "Re: " + subject
+	}
+	headers = append(headers, "Subject: "+core.EncodeMIMESubject(strings.TrimSpace(subject), encoding))
+
+	if id := strings.TrimSpace(message.Header.Get("Message-ID")); id != "" {
+		headers = append(headers, "In-Reply-To: "+id)
+	}
+
+	body, err := plainTextPart(textproto.MIMEHeader(message.Header), message.Body)
+	if err != nil {
+		return "", 0, err
+	}
+
+	text := strings.Join(headers, "\n") + "\n\n\n\n" + quoteText(body)
+	// The subject may be folded over several lines
+	return text, strings.Count(strings.Join(headers, "\n"), "\n") + 2, nil
+}
+
+// replyToSelected opens a reply to the selected inbox message in the
+// Compose tab, asking first if that would replace a message being written
+func (q *QuickMail) replyToSelected() {
+	if q.inboxSelected < 0 || q.inboxSelected >= len(q.inbox) {
+		q.showError("No message selected")
+		return
+	}
+
+	data, err := os.ReadFile(q.inbox[q.inboxSelected].Path)
+	if err != nil {
+		q.showError(fmt.Sprintf("Could not read message: %v", err))
+		return
+	}
+	text, row, err := replyText(string(data), q.config.WordEncoder())
+	if err != nil {
+		q.showError(fmt.Sprintf("Reply error: %v", err))
+		return
+	}
+
+	startReply := func() {
+		q.textArea.SetText(text)
+		q.tabs.SelectIndex(0)
+		q.textArea.CursorRow, q.textArea.CursorColumn = row, 0
+		q.textArea.Refresh()
+		q.window.Canvas().Focus(q.textArea)
+	}
+
+	if strings.TrimSpace(q.textArea.Text) == "" {
+		startReply()
+		return
+	}
+	dialog.ShowConfirm("Replace Message?",
+		"The message being composed will be replaced by the reply.\nContinue?",
+		func(replace bool) {
+			if replace {
+				startReply()
+			}
+		}, q.window)
+}
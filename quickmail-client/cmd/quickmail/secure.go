@@ -0,0 +1,18 @@
+package main
+
+import (
+	"github.com/awnumar/memguard"
+)
+
+// A prepared message is held in a memguard LockedBuffer from the moment it
+// is queued for sending until it was uploaded or moved to the outbox. The
+// buffer is kept out of swap, the upload reads straight from it and it is
+// wiped when destroyed. The entry widget and the steps that build the
+// message from it work on Go strings, which cannot be wiped, so this only
+// narrows the places the message text lingers in memory.
+
+// lockMessage copies message into a new LockedBuffer, which the caller
+// destroys once the message was sent or given up
+func lockMessage(message string) *memguard.LockedBuffer {
+	return memguard.NewBufferFromBytes([]byte(message))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2"
+	"github.com/awnumar/memguard"
+)
+
+// sendQueueSize is the number of messages that can wait for the send
+// worker
+const sendQueueSize = 16
+
+// sendJob is a finished message waiting to be uploaded. original is the
+// composed text, whose draft is deleted once the message was sent. body is
+// the message to upload in locked memory, which is destroyed once the job
+// is done or dropped.
+type sendJob struct {
+	original   string
+	serverName string
+	url        string
+	body       *memguard.LockedBuffer
+	bcc        []string
+	policy     core.RetryPolicy
+}
+
+// startSendWorker starts the goroutine that uploads queued messages one
+// after another, so a message sent while another is still uploading over
+// a slow circuit does not share the Tor client with it
+func (q *QuickMail) startSendWorker() {
+	q.sendQueue = make(chan sendJob, sendQueueSize)
+
+	go func() {
+		for job := range q.sendQueue {
+			q.runSendJob(job)
+			q.sendPending.Add(-1)
+			q.setInFlight(job.original, false)
+			q.updateSendBadge()
+		}
+	}()
+}
+
+// enqueueSend adds a message to the send queue. A message that is already
+// queued or uploading is not added again.
+func (q *QuickMail) enqueueSend(job sendJob) {
+	if !q.setInFlight(job.original, true) {
+		job.body.Destroy()
+		return
+	}
+
+	pending := q.sendPending.Add(1)
+	select {
+	case q.sendQueue <- job:
+	default:
+		q.sendPending.Add(-1)
+		q.setInFlight(job.original, false)
+		job.body.Destroy()
+		q.showError(fmt.Sprintf("%d messages are already waiting to be sent. Try again once some have been sent.", sendQueueSize))
+		return
+	}
+
+	q.updateSendBadge()
+	if pending > 1 {
+		q.setStatus(fmt.Sprintf("Message queued, %d ahead of it", pending-1))
+	}
+}
+
+// updateSendBadge shows the number of messages waiting or uploading on the
+// Send button; it may be called from any goroutine
+func (q *QuickMail) updateSendBadge() {
+	if q.sendButton == nil {
+		return
+	}
+	text := "Send"
+	if pending := q.sendPending.Load(); pending > 0 {
+		text = fmt.Sprintf("Send (%d)", pending)
+	}
+	fyne.Do(func() {
+		q.sendButton.SetText(text)
+	})
+}
+
+// setInFlight marks the message with the composed text original as queued
+// or uploading, or clears the mark, and updates the Send button. It
+// reports false if the message was already marked. It may be called from
+// any goroutine.
+func (q *QuickMail) setInFlight(original string, inFlight bool) bool {
+	q.inFlightMu.Lock()
+	if inFlight && q.inFlight[original] {
+		q.inFlightMu.Unlock()
+		return false
+	}
+	if inFlight {
+		if q.inFlight == nil {
+			q.inFlight = make(map[string]bool)
+		}
+		q.inFlight[original] = true
+	} else {
+		delete(q.inFlight, original)
+	}
+	q.inFlightMu.Unlock()
+
+	fyne.Do(q.updateSendEnabled)
+	return true
+}
+
+// isInFlight reports whether the message with the composed text original
+// is queued or uploading
+func (q *QuickMail) isInFlight(original string) bool {
+	q.inFlightMu.Lock()
+	defer q.inFlightMu.Unlock()
+	return q.inFlight[original]
+}
+
+// updateSendEnabled enables the Send button once Tor is reachable, unless
+// the composed message is already queued or uploading
+func (q *QuickMail) updateSendEnabled() {
+	if q.sendButton == nil {
+		return
+	}
+	if q.torReady.Load() && !q.isInFlight(q.textArea.Text) {
+		q.sendButton.Enable()
+	} else {
+		q.sendButton.Disable()
+	}
+}
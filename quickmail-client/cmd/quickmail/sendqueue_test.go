@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2/test"
+)
+
+// slowServer stands in for a QuickMail server behind a slow circuit: every
+// upload is announced on started and answered once release is closed
+type slowServer struct {
+	*httptest.Server
+	requests atomic.Int32
+	started  chan struct{}
+	release  chan struct{}
+}
+
+func newSlowServer(t *testing.T) *slowServer {
+	s := &slowServer{
+		started: make(chan struct{}, sendQueueSize),
+		release: make(chan struct{}),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		s.started <- struct{}{}
+		<-s.release
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *slowServer) waitStarted(t *testing.T) {
+	t.Helper()
+	select {
+	case <-s.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the upload did not reach the server")
+	}
+}
+
+// newSOCKSProxy starts a minimal SOCKS5 proxy standing in for Tor, which
+// connects every CONNECT request to target, and returns its address
+func newSOCKSProxy(t *testing.T, target string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS5(conn, target)
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// serveSOCKS5 answers the handshake without authentication, or with any
+// user name and password, and relays the connection to target
+func serveSOCKS5(client net.Conn, target string) {
+	defer client.Close()
+	r := bufio.NewReader(client)
+
+	greeting := make([]byte, 2)
+	if _, err := io.ReadFull(r, greeting); err != nil {
+		return
+	}
+	methods := make([]byte, greeting[1])
+	if _, err := io.ReadFull(r, methods); err != nil {
+		return
+	}
+	method := byte(0)
+	for _, m := range methods {
+		if m == 2 {
+			method = 2
+		}
+	}
+	client.Write([]byte{5, method})
+	if method == 2 {
+		// Version, then the length-prefixed user name and password
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return
+		}
+		if _, err := r.Discard(int(header[1])); err != nil {
+			return
+		}
+		n, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		if _, err := r.Discard(int(n)); err != nil {
+			return
+		}
+		client.Write([]byte{1, 0})
+	}
+
+	// CONNECT to a domain name, which Tor resolves
+	request := make([]byte, 5)
+	if _, err := io.ReadFull(r, request); err != nil || request[3] != 3 {
+		return
+	}
+	if _, err := r.Discard(int(request[4]) + 2); err != nil {
+		return
+	}
+
+	server, err := net.Dial("tcp", target)
+	if err != nil {
+		return
+	}
+	defer server.Close()
+	client.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go io.Copy(server, r)
+	io.Copy(client, server)
+}
+
+// newTestSender returns a QuickMail with a running send worker that
+// uploads to server through a fake Tor proxy and keeps its files in a
+// temporary config directory. It has no Send button: the worker updates
+// the button through fyne.Do, which the test driver may run on another
+// goroutine, so the tests check the in-flight marks that decide whether
+// Send is enabled instead.
+func newTestSender(t *testing.T, server *slowServer) *QuickMail {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	app := test.NewTempApp(t)
+	q := &QuickMail{
+		app:      app,
+		window:   app.NewWindow("Quick Mail"),
+		config:   &core.Config{},
+		textArea: newMessageEntry(),
+		torProxy: newSOCKSProxy(t, server.Listener.Addr().String()),
+	}
+	q.torReady.Store(true)
+	q.startSendWorker()
+	return q
+}
+
+// testServerURL is the onion address the test messages are sent to; the
+// fake proxy connects it to the slow server
+const testServerURL = "http://yourserversfiftysixcharacterversionthreeonionaddressgoes.onion:8088/upload"
+
+func newTestJob(text string) sendJob {
+	return sendJob{
+		original: text,
+		url:      testServerURL,
+		body:     lockMessage(text),
+		policy:   core.RetryPolicy{MaxAttempts: 1},
+	}
+}
+
+// waitIdle waits until the send worker has finished every job and
+// cleared its in-flight marks
+func waitIdle(t *testing.T, q *QuickMail) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for q.sendPending.Load() > 0 || q.inFlightCount() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d messages still pending, %d marked in flight", q.sendPending.Load(), q.inFlightCount())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSendTwiceWhileUploading(t *testing.T) {
+	server := newSlowServer(t)
+	q := newTestSender(t, server)
+
+	const message = "To: alice@example.org\nSubject: Once\n\nSend me only once.\n"
+
+	q.enqueueSend(newTestJob(message))
+	server.waitStarted(t)
+
+	if !q.isInFlight(message) {
+		t.Error("the message is not marked while it is uploading, so Send stays enabled")
+	}
+	if n := q.sendPending.Load(); n != 1 {
+		t.Errorf("%d messages pending, want 1", n)
+	}
+
+	// A second click on the same message, e.g. via the keyboard shortcut
+	duplicate := newTestJob(message)
+	q.enqueueSend(duplicate)
+	if duplicate.body.IsAlive() {
+		t.Error("the rejected duplicate's locked buffer was not destroyed")
+	}
+	if n := q.sendPending.Load(); n != 1 {
+		t.Errorf("%d messages pending after the duplicate, want 1", n)
+	}
+
+	close(server.release)
+	waitIdle(t, q)
+
+	if n := server.requests.Load(); n != 1 {
+		t.Errorf("server received %d uploads, want 1", n)
+	}
+	if q.isInFlight(message) {
+		t.Error("the message is still marked after the upload, so Send stays disabled")
+	}
+}
+
+func TestSendOtherMessageWhileUploading(t *testing.T) {
+	server := newSlowServer(t)
+	q := newTestSender(t, server)
+
+	const first = "Subject: First\n\none\n"
+	const second = "Subject: Second\n\ntwo\n"
+
+	q.enqueueSend(newTestJob(first))
+	server.waitStarted(t)
+
+	// The next message is composed and sent while the first is uploading
+	if q.isInFlight(second) {
+		t.Error("a different message is marked, so Send is disabled for it")
+	}
+	q.enqueueSend(newTestJob(second))
+	if n := q.sendPending.Load(); n != 2 {
+		t.Errorf("%d messages pending, want 2", n)
+	}
+
+	close(server.release)
+	waitIdle(t, q)
+
+	if n := server.requests.Load(); n != 2 {
+		t.Errorf("server received %d uploads, want 2", n)
+	}
+}
+
+// inFlightCount returns the number of messages marked as queued or
+// uploading
+func (q *QuickMail) inFlightCount() int {
+	q.inFlightMu.Lock()
+	defer q.inFlightMu.Unlock()
+	return len(q.inFlight)
+}
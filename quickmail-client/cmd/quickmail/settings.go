@@ -0,0 +1,320 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// numberValidator returns an entry validator accepting whole numbers of at
+// least min
+func numberValidator(name string, min int) func(string) error {
+	return func(s string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n < min {
+			return fmt.Errorf("%s must be a number of at least %d", name, min)
+		}
+		return nil
+	}
+}
+
+// showSettingsDialog lets the user edit the active server profile and the
+// other settings of quickmail.json. Invalid fields are marked in the form
+// and keep it from being saved. Saved settings take effect at once.
+func (q *QuickMail) showSettingsDialog() {
+	current := core.Config{}
+	if q.config != nil {
+		current = *q.config
+		current.Servers = append([]core.ServerProfile(nil), q.config.Servers...)
+	}
+
+	server := core.ServerProfile{Name: core.DefaultProfileName}
+	if p := current.Profile(q.activeProfile); p != nil {
+		server = *p
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(server.Name)
+	nameEntry.Validator = func(s string) error {
+		if strings.TrimSpace(s) == "" {
+			return errors.New("profile name is empty")
+		}
+		return nil
+	}
+
+	tlsCheck := widget.NewCheck("Use HTTPS for servers that are not onion services", nil)
+	tlsCheck.SetChecked(current.UseTLS)
+
+	proxyTypeSelect := widget.NewSelect([]string{core.ProxySOCKS5, core.ProxySOCKS4a, core.ProxyDirect}, nil)
+	proxyTypeSelect.SetSelected(current.ProxyProtocol())
+
+	addressEntry := widget.NewEntry()
+	addressEntry.SetText(server.OnionAddress)
+	addressEntry.PlaceHolder = "http://yourserversfiftysixcharacterversionthreeonionaddressgoes.onion"
+	addressEntry.Validator = core.ValidateServerAddress
+
+	portEntry := widget.NewEntry()
+	portEntry.SetText(server.Port)
+	portEntry.PlaceHolder = "8088"
+	portEntry.Validator = core.ValidatePort
+
+	caCertEntry := widget.NewEntry()
+	caCertEntry.SetText(current.TLSCACert)
+	caCertEntry.PlaceHolder = "PEM file of the server CA, empty for the system CAs"
+	caCertEntry.Validator = func(s string) error {
+		if !tlsCheck.Checked || strings.TrimSpace(s) == "" {
+			return nil
+		}
+		_, err := core.NewTLSConfig(strings.TrimSpace(s))
+		return err
+	}
+
+	tlsCheck.OnChanged = func(bool) {
+		caCertEntry.Validate()
+	}
+
+	proxyEntry := widget.NewEntry()
+	proxyEntry.SetText(current.TorProxy)
+	proxyEntry.PlaceHolder = core.DefaultTorProxy + " or unix:/run/tor/socks"
+	proxyEntry.Validator = func(s string) error {
+		if strings.TrimSpace(s) == "" {
+			return nil
+		}
+		return core.ValidateProxyAddress(s)
+	}
+
+	proxyUserEntry := widget.NewEntry()
+	proxyUserEntry.SetText(current.ProxyUser)
+	proxyUserEntry.PlaceHolder = "Only for proxies requiring a login"
+
+	proxyPassEntry := widget.NewPasswordEntry()
+	proxyPassEntry.SetText(current.ProxyPass)
+
+	circuitCheck := widget.NewCheck("Confirm the Tor circuit at check.torproject.org", nil)
+	circuitCheck.SetChecked(current.CheckTorCircuit)
+
+	isolateCheck := widget.NewCheck("Send every message over a new Tor circuit", nil)
+	isolateCheck.SetChecked(current.ShouldIsolateStreams())
+
+	timeoutEntry := widget.NewEntry()
+	if current.SendTimeoutSeconds != nil {
+		timeoutEntry.SetText(strconv.Itoa(*current.SendTimeoutSeconds))
+	}
+	timeoutEntry.PlaceHolder = "Automatic"
+	timeoutEntry.Validator = func(s string) error {
+		if strings.TrimSpace(s) == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return errors.New("timeout must be a number of seconds")
+		}
+		return core.ValidateSendTimeout(n)
+	}
+
+	connectTimeoutEntry := widget.NewEntry()
+	connectTimeoutEntry.SetText(strconv.Itoa(int(current.ConnectTimeout() / time.Second)))
+	connectTimeoutEntry.Validator = numberValidator("connect timeout", 1)
+
+	retryEntry := widget.NewEntry()
+	retryEntry.SetText(strconv.Itoa(current.RetryPolicy().MaxAttempts - 1))
+	retryEntry.Validator = numberValidator("retries", 0)
+
+	maxBytesEntry := widget.NewEntry()
+	maxBytesEntry.SetText(strconv.Itoa(current.MaxMessageBytes))
+	maxBytesEntry.Validator = numberValidator("size limit", 0)
+
+	themeSelect := widget.NewSelect([]string{core.ThemeDark, core.ThemeLight, core.ThemeSystem}, nil)
+	themeSelect.PlaceHolder = "Theme switch"
+	themeSelect.Selected = current.ThemeMode()
+
+	encodingSelect := widget.NewSelect([]string{"B", "Q"}, nil)
+	encodingSelect.SetSelected("B")
+	if strings.EqualFold(current.SubjectEncoding, "Q") {
+		encodingSelect.SetSelected("Q")
+	}
+
+	confirmSendCheck := widget.NewCheck("Before sending", nil)
+	confirmSendCheck.SetChecked(current.ShouldConfirmSend())
+	confirmClearCheck := widget.NewCheck("Before clearing", nil)
+	confirmClearCheck.SetChecked(current.ShouldConfirmClear())
+
+	recipientKeyEntry := widget.NewEntry()
+	recipientKeyEntry.SetText(current.RecipientKeyPath)
+	recipientKeyEntry.PlaceHolder = "Armored public key for Encrypt"
+
+	signingKeyEntry := widget.NewEntry()
+	signingKeyEntry.SetText(current.SigningKeyPath)
+	signingKeyEntry.PlaceHolder = "Armored secret key for Sign"
+
+	pollEntry := widget.NewEntry()
+	pollEntry.SetText(strconv.Itoa(current.PollMinutes))
+	pollEntry.Validator = numberValidator("poll interval", 0)
+
+	deleteCheck := widget.NewCheck("Delete from server after download", nil)
+	deleteCheck.SetChecked(current.DeleteAfterDownload)
+
+	batchCheck := widget.NewCheck("Continue a batch send after a failed message", nil)
+	batchCheck.SetChecked(current.BatchContinueOnError)
+
+	draftsCheck := widget.NewCheck("Autosave the message as a draft", nil)
+	draftsCheck.SetChecked(current.ShouldSaveDrafts())
+
+	draftIntervalEntry := widget.NewEntry()
+	draftIntervalEntry.SetText(strconv.Itoa(int(q.draftInterval().Seconds())))
+	draftIntervalEntry.Validator = numberValidator("draft interval", 1)
+
+	clipboardEntry := widget.NewEntry()
+	clipboardEntry.SetText(strconv.Itoa(int(current.ClipboardClearDelay().Seconds())))
+	clipboardEntry.Validator = numberValidator("clipboard timeout", 0)
+
+	var settingsDialog dialog.Dialog
+	form := &widget.Form{
+		Items: []*widget.FormItem{
+			widget.NewFormItem("Profile name:", nameEntry),
+			widget.NewFormItem("Onion address:", addressEntry),
+			widget.NewFormItem("Port:", portEntry),
+			widget.NewFormItem("TLS:", tlsCheck),
+			widget.NewFormItem("TLS CA cert:", caCertEntry),
+			widget.NewFormItem("Tor proxy:", proxyEntry),
+			{Text: "Proxy type:", Widget: proxyTypeSelect, HintText: "direct bypasses Tor, for testing only"},
+			widget.NewFormItem("Proxy user:", proxyUserEntry),
+			widget.NewFormItem("Proxy password:", proxyPassEntry),
+			widget.NewFormItem("Tor check:", circuitCheck),
+			{Text: "Isolation:", Widget: isolateCheck, HintText: "Not possible with a proxy user"},
+			{Text: "Send timeout:", Widget: timeoutEntry, HintText: "Seconds, 0 for none, empty to allow for the message size"},
+			{Text: "Connect timeout:", Widget: connectTimeoutEntry, HintText: "Seconds, including the Tor circuit"},
+			widget.NewFormItem("Max retries:", retryEntry),
+			{Text: "Size limit:", Widget: maxBytesEntry, HintText: "Bytes, 0 for none"},
+			widget.NewFormItem("Subject encoding:", encodingSelect),
+			{Text: "Theme:", Widget: themeSelect, HintText: "system follows the desktop"},
+			widget.NewFormItem("Confirm:", container.NewHBox(confirmSendCheck, confirmClearCheck)),
+			widget.NewFormItem("Recipient key:", recipientKeyEntry),
+			widget.NewFormItem("Signing key:", signingKeyEntry),
+			{Text: "Poll interval:", Widget: pollEntry, HintText: "Minutes, 0 to poll only on demand"},
+			widget.NewFormItem("Inbox:", deleteCheck),
+			widget.NewFormItem("Batch send:", batchCheck),
+			widget.NewFormItem("Drafts:", draftsCheck),
+			{Text: "Draft interval:", Widget: draftIntervalEntry, HintText: "Seconds"},
+			{Text: "Clear clipboard:", Widget: clipboardEntry, HintText: "Seconds after the last copy, 0 for never"},
+		},
+		SubmitText: "Save",
+		CancelText: "Cancel",
+		OnCancel: func() {
+			settingsDialog.Hide()
+		},
+	}
+	form.OnSubmit = func() {
+		updatedServer := core.ServerProfile{
+			Name:         strings.TrimSpace(nameEntry.Text),
+			OnionAddress: strings.TrimSpace(addressEntry.Text),
+			Port:         strings.TrimSpace(portEntry.Text),
+		}
+		if updatedServer.Name != server.Name && current.Profile(updatedServer.Name) != nil {
+			q.showError(fmt.Sprintf("A profile named %q already exists", updatedServer.Name))
+			return
+		}
+
+		updated := current
+		updated.UseTLS = tlsCheck.Checked
+		updated.TLSCACert = strings.TrimSpace(caCertEntry.Text)
+		updated.TorProxy = strings.TrimSpace(proxyEntry.Text)
+		updated.ProxyType = proxyTypeSelect.Selected
+		updated.ProxyUser = strings.TrimSpace(proxyUserEntry.Text)
+		updated.ProxyPass = proxyPassEntry.Text
+		updated.CheckTorCircuit = circuitCheck.Checked
+		isolate := isolateCheck.Checked
+		updated.IsolateStreams = &isolate
+
+		updated.SendTimeoutSeconds = nil
+		if text := strings.TrimSpace(timeoutEntry.Text); text != "" {
+			timeout, _ := strconv.Atoi(text)
+			updated.SendTimeoutSeconds = &timeout
+		}
+		updated.ConnectTimeoutSeconds, _ = strconv.Atoi(strings.TrimSpace(connectTimeoutEntry.Text))
+		maxRetries, _ := strconv.Atoi(strings.TrimSpace(retryEntry.Text))
+		updated.MaxRetries = &maxRetries
+		updated.MaxMessageBytes, _ = strconv.Atoi(strings.TrimSpace(maxBytesEntry.Text))
+		updated.SubjectEncoding = encodingSelect.Selected
+		updated.Theme = themeSelect.Selected
+		confirmSend, confirmClear := confirmSendCheck.Checked, confirmClearCheck.Checked
+		updated.ConfirmSend = &confirmSend
+		updated.ConfirmClear = &confirmClear
+
+		updated.RecipientKeyPath = strings.TrimSpace(recipientKeyEntry.Text)
+		updated.SigningKeyPath = strings.TrimSpace(signingKeyEntry.Text)
+		updated.PollMinutes, _ = strconv.Atoi(strings.TrimSpace(pollEntry.Text))
+		updated.DeleteAfterDownload = deleteCheck.Checked
+		updated.BatchContinueOnError = batchCheck.Checked
+		drafts := draftsCheck.Checked
+		updated.Drafts = &drafts
+		updated.DraftIntervalSeconds, _ = strconv.Atoi(strings.TrimSpace(draftIntervalEntry.Text))
+		clipboardSeconds, _ := strconv.Atoi(strings.TrimSpace(clipboardEntry.Text))
+		updated.ClipboardClearSeconds = &clipboardSeconds
+
+		if p := updated.Profile(server.Name); p != nil {
+			*p = updatedServer
+		} else {
+			updated.Servers = append(updated.Servers, updatedServer)
+		}
+		if updated.DefaultProfile == server.Name {
+			updated.DefaultProfile = updatedServer.Name
+		}
+
+		if err := core.SaveConfig(&updated); err != nil {
+			q.showError(fmt.Sprintf("Could not save settings: %v", err))
+			return
+		}
+		settingsDialog.Hide()
+		q.applySettings(&current, &updated)
+		q.refreshProfiles(updatedServer.Name)
+	}
+
+	settingsDialog = dialog.NewCustomWithoutButtons("Settings", container.NewVScroll(form), q.window)
+	settingsDialog.Show()
+	settingsDialog.Resize(fyne.NewSize(560, 560))
+}
+
+// applySettings makes saved settings take effect without a restart,
+// checking Tor again after a proxy change and restarting polling and
+// draft autosave when their settings changed. The draft is deleted when
+// drafts are turned off.
+func (q *QuickMail) applySettings(old, updated *core.Config) {
+	q.configMu.Lock()
+	q.config = updated
+	q.configMu.Unlock()
+
+	if updated.TorProxy != old.TorProxy || updated.ProxyProtocol() != old.ProxyProtocol() || updated.ProxyUser != old.ProxyUser ||
+		updated.ProxyPass != old.ProxyPass || updated.CheckTorCircuit != old.CheckTorCircuit {
+		q.checkTor()
+	}
+
+	if !updated.ShouldSaveDrafts() && old.ShouldSaveDrafts() {
+		q.deleteDraft(q.textArea.Text)
+	}
+
+	if updated.PollMinutes != old.PollMinutes ||
+		updated.ShouldSaveDrafts() != old.ShouldSaveDrafts() || updated.DraftIntervalSeconds != old.DraftIntervalSeconds {
+		if updated.PollMinutes > 0 {
+			q.pollLabel.Show()
+		} else {
+			q.pollLabel.Hide()
+		}
+		q.startBackground()
+	}
+
+	if updated.ThemeMode() != old.ThemeMode() && updated.ThemeMode() != "" {
+		q.applyTheme(newThemePreference(q.app, updated).mode())
+	}
+
+	q.updateStats(q.textArea.Text)
+}
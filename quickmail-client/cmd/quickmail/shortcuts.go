@@ -0,0 +1,142 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+// shortcutAction is a keyboard shortcut together with the action it
+// triggers and the description shown in the help dialog
+type shortcutAction struct {
+	menu     string
+	label    string
+	shortcut *desktop.CustomShortcut
+	action   func()
+}
+
+// primaryModifier is Command on macOS and Control everywhere else
+const primaryModifier = fyne.KeyModifierShortcutDefault
+
+// shortcutActions returns the keyboard shortcuts of the main window
+func (q *QuickMail) shortcutActions() []shortcutAction {
+	return []shortcutAction{
+		{"Message", "Send", &desktop.CustomShortcut{KeyName: fyne.KeyReturn, Modifier: primaryModifier}, q.sendFromShortcut},
+		{"File", "New", &desktop.CustomShortcut{KeyName: fyne.KeyN, Modifier: primaryModifier}, q.confirmClear},
+		{"File", "Open...", &desktop.CustomShortcut{KeyName: fyne.KeyO, Modifier: primaryModifier}, q.showOpenFileDialog},
+		{"File", "Save...", &desktop.CustomShortcut{KeyName: fyne.KeyS, Modifier: primaryModifier}, q.showSaveFileDialog},
+		{"Message", "MIME Subject...", &desktop.CustomShortcut{KeyName: fyne.KeyM, Modifier: primaryModifier}, q.showSubjectDialog},
+		{"Message", "Find and Replace...", &desktop.CustomShortcut{KeyName: fyne.KeyF, Modifier: primaryModifier}, q.showFindReplaceDialog},
+		{"Edit", "Clear", &desktop.CustomShortcut{KeyName: fyne.KeyL, Modifier: primaryModifier}, q.confirmClear},
+		{"Edit", "Undo", &desktop.CustomShortcut{KeyName: fyne.KeyZ, Modifier: primaryModifier}, q.undo},
+		{"Edit", "Redo", &desktop.CustomShortcut{KeyName: fyne.KeyY, Modifier: primaryModifier}, q.redo},
+		{"View", "Larger Font", &desktop.CustomShortcut{KeyName: fyne.KeyEqual, Modifier: primaryModifier}, q.increaseFontSize},
+		{"View", "Smaller Font", &desktop.CustomShortcut{KeyName: fyne.KeyMinus, Modifier: primaryModifier}, q.decreaseFontSize},
+		{"View", "Reset Font Size", &desktop.CustomShortcut{KeyName: fyne.Key0, Modifier: primaryModifier}, q.resetFontSize},
+		{"View", "Toggle Theme", &desktop.CustomShortcut{KeyName: fyne.KeyT, Modifier: primaryModifier}, q.toggleTheme},
+		{"View", "Toggle Word Wrap", &desktop.CustomShortcut{KeyName: fyne.KeyZ, Modifier: fyne.KeyModifierAlt}, q.toggleWordWrap},
+		{"View", "Toggle Line Numbers", &desktop.CustomShortcut{KeyName: fyne.KeyL, Modifier: fyne.KeyModifierAlt}, q.toggleLineNumbers},
+		{"View", "Toggle Monospace", &desktop.CustomShortcut{KeyName: fyne.KeyM, Modifier: fyne.KeyModifierAlt}, q.toggleMonospace},
+	}
+}
+
+// shortcutTip returns the tooltip of the button for the action with the
+// given label, like "Send (Ctrl+Enter)"
+func (q *QuickMail) shortcutTip(label string) string {
+	for _, s := range q.shortcutActions() {
+		if s.label == label {
+			return strings.TrimSuffix(label, "...") + " (" + shortcutText(s.shortcut) + ")"
+		}
+	}
+	return strings.TrimSuffix(label, "...")
+}
+
+// sendFromShortcut sends like the Send button, and like the button does
+// nothing while it is disabled
+func (q *QuickMail) sendFromShortcut() {
+	if q.sendButton == nil || q.sendButton.Disabled() {
+		return
+	}
+	q.sendIfReady()
+}
+
+// modalOpen reports whether a dialog is currently shown over the window
+func (q *QuickMail) modalOpen() bool {
+	return q.window.Canvas().Overlays().Top() != nil
+}
+
+// unlessModal wraps action so it does nothing while a dialog is open
+func (q *QuickMail) unlessModal(action func()) func() {
+	return func() {
+		if q.modalOpen() {
+			return
+		}
+		action()
+	}
+}
+
+// registerShortcuts attaches the keyboard shortcuts of the named menu to
+// the window canvas and returns them as menu items. The menu items make
+// the shortcuts work while the message entry has focus, since the main
+// menu is consulted before the focused widget.
+func (q *QuickMail) registerShortcuts(menu string) []*fyne.MenuItem {
+	var items []*fyne.MenuItem
+	for _, s := range q.shortcutActions() {
+		if s.menu != menu {
+			continue
+		}
+		action := q.unlessModal(s.action)
+		q.window.Canvas().AddShortcut(s.shortcut, func(fyne.Shortcut) {
+			action()
+		})
+
+		item := fyne.NewMenuItem(s.label, action)
+		item.Shortcut = s.shortcut
+		items = append(items, item)
+	}
+	return items
+}
+
+// showShortcutsDialog lists the keyboard shortcuts
+func (q *QuickMail) showShortcutsDialog() {
+	grid := container.NewGridWithColumns(2)
+	for _, s := range q.shortcutActions() {
+		grid.Add(widget.NewLabel(shortcutText(s.shortcut)))
+		grid.Add(widget.NewLabel(strings.TrimSuffix(s.label, "...")))
+	}
+
+	dialog.ShowCustom("Keyboard Shortcuts", "Close", grid, q.window)
+}
+
+// shortcutText returns a readable form of a shortcut like "Ctrl+Enter",
+// or "Cmd+Enter" on macOS
+func shortcutText(s *desktop.CustomShortcut) string {
+	key := string(s.KeyName)
+	if s.KeyName == fyne.KeyReturn {
+		key = "Enter"
+	}
+
+	var parts []string
+	if s.Modifier&fyne.KeyModifierSuper != 0 {
+		if runtime.GOOS == "darwin" {
+			parts = append(parts, "Cmd")
+		} else {
+			parts = append(parts, "Super")
+		}
+	}
+	if s.Modifier&fyne.KeyModifierControl != 0 {
+		parts = append(parts, "Ctrl")
+	}
+	if s.Modifier&fyne.KeyModifierAlt != 0 {
+		parts = append(parts, "Alt")
+	}
+	if s.Modifier&fyne.KeyModifierShift != 0 {
+		parts = append(parts, "Shift")
+	}
+	return strings.Join(append(parts, key), "+")
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+// countStats returns the number of lines, words and bytes in text. An
+// empty text has no lines, otherwise a trailing line without newline
+// still counts.
+func countStats(text string) (lines, words, bytes int) {
+	if text == "" {
+		return 0, 0, 0
+	}
+	return strings.Count(text, "\n") + 1, len(strings.Fields(text)), len(text)
+}
+
+// newStatsLabel creates the label below the buttons showing the size of
+// the composition. Bytes is the length of the UTF-8 text uploadMessage
+// transmits, before PGP and attachments are applied.
+func (q *QuickMail) newStatsLabel() *widget.Label {
+	q.statsLabel = widget.NewLabel("")
+	q.updateStats(q.textArea.Text)
+	return q.statsLabel
+}
+
+// updateStats refreshes the stats label for text and turns it red when
+// the message is larger than core.Config.MaxMessageBytes
+func (q *QuickMail) updateStats(text string) {
+	if q.statsLabel == nil {
+		return
+	}
+
+	lines, words, bytes := countStats(text)
+	chars := utf8.RuneCountInString(text)
+	q.statsLabel.Importance = widget.MediumImportance
+	if q.config != nil && q.config.MaxMessageBytes > 0 && bytes > q.config.MaxMessageBytes {
+		q.statsLabel.Importance = widget.DangerImportance
+	}
+	q.statsLabel.SetText(fmt.Sprintf("Chars: %d  Words: %d  Lines: %d  Bytes: %d", chars, words, lines, bytes))
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestCountStats(t *testing.T) {
+	tests := []struct {
+		name                string
+		text                string
+		lines, words, bytes int
+	}{
+		{"empty", "", 0, 0, 0},
+		{"one word", "hello", 1, 1, 5},
+		{"trailing newline starts a line", "hello\n", 2, 1, 6},
+		{"blank lines", "\n\n", 3, 0, 2},
+		{"whitespace only", " \t ", 1, 0, 3},
+		{"several spaces between words", "one   two\tthree", 1, 3, 15},
+		{"message", "Subject: Hi\n\nHello, World!\n", 4, 4, 27},
+		{"multi-byte characters count as bytes", "Grüße 🔒", 1, 2, 12},
+		{"crlf", "a\r\nb", 2, 2, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, words, bytes := countStats(tt.text)
+			if lines != tt.lines || words != tt.words || bytes != tt.bytes {
+				t.Errorf("countStats(%q) = %d lines, %d words, %d bytes, want %d, %d, %d",
+					tt.text, lines, words, bytes, tt.lines, tt.words, tt.bytes)
+			}
+		})
+	}
+}
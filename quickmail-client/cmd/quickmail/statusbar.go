@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+// newServerLabel creates the status bar label naming the server Send
+// uploads to
+func (q *QuickMail) newServerLabel() *widget.Label {
+	q.serverLabel = widget.NewLabel("")
+	q.updateServerLabel()
+	return q.serverLabel
+}
+
+// updateServerLabel shows the active server profile in the status bar; it
+// may be called from any goroutine
+func (q *QuickMail) updateServerLabel() {
+	if q.serverLabel == nil {
+		return
+	}
+
+	text := "No server"
+	if q.config != nil {
+		if server := q.config.Profile(q.activeProfile); server != nil {
+			text = fmt.Sprintf("Server: %s (%s)", server.Name, shortHost(server.BaseURL(q.config.UseTLS)))
+		}
+	}
+	fyne.Do(func() {
+		q.serverLabel.SetText(text)
+	})
+}
+
+// shortHost returns the host and port of address with a long onion name
+// shortened to its first characters, which is enough to tell servers
+// apart
+func shortHost(address string) string {
+	u, err := url.Parse(address)
+	if err != nil || u.Host == "" {
+		return address
+	}
+
+	host := u.Hostname()
+	if name, ok := strings.CutSuffix(host, ".onion"); ok && len(name) > 12 {
+		host = name[:8] + "….onion"
+	}
+	if port := u.Port(); port != "" {
+		host += ":" + port
+	}
+	return host
+}
+
+// newLastSendLabel creates the status bar label showing the result of the
+// last send
+func (q *QuickMail) newLastSendLabel() *widget.Label {
+	q.lastSendLabel = widget.NewLabel("Last send: none")
+	return q.lastSendLabel
+}
+
+// setLastSend remembers how long the last send took and shows it with its
+// outcome in the status bar, such as "Last send: 00:01:23 (sent)", so the
+// speed of the Tor circuit can be judged; it may be called from any
+// goroutine
+func (q *QuickMail) setLastSend(elapsed time.Duration, outcome string, importance widget.Importance) {
+	if q.lastSendLabel == nil {
+		return
+	}
+	fyne.Do(func() {
+		q.lastSendDuration = elapsed
+		q.lastSendLabel.Importance = importance
+		q.lastSendLabel.SetText(fmt.Sprintf("Last send: %s (%s)", q.formatDuration(elapsed), outcome))
+	})
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Templates are plain UTF-8 .txt files in the templates directory next to
+// the config. They are message skeletons and are stored unencrypted.
+// Inserting a template replaces the placeholders {{date}}, {{time}} and
+// {{subject}}; other text in double braces is kept as it is.
+
+// templateExt is the file extension of templates
+const templateExt = ".txt"
+
+// placeholderPattern matches a {{name}} placeholder
+var placeholderPattern = regexp.MustCompile(`\{\{(\w+)\}\}`)
+
+// templateValues returns the placeholder values for a template inserted
+// at now into a message with the given subject. The date is in the RFC
+// 5322 format of the Date: header.
+func templateValues(now time.Time, subject string) map[string]string {
+	return map[string]string{
+		"date":    now.Format(time.RFC1123Z),
+		"time":    now.Format("15:04"),
+		"subject": subject,
+	}
+}
+
+// expandPlaceholders replaces the placeholders in text with values, leaving
+// unknown ones intact
+func expandPlaceholders(text string, values map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := placeholder[2 : len(placeholder)-2]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
+
+// insertTemplate inserts template text at the cursor with its placeholders
+// replaced; {{subject}} is the subject of the message being composed
+func (q *QuickMail) insertTemplate(text string) {
+	values := templateValues(time.Now(), core.MessageSubject(q.textArea.Text))
+	q.insertAtCursor(expandPlaceholders(text, values))
+	q.window.Canvas().Focus(q.textArea)
+}
+
+// templatesDir returns the templates directory, creating it if necessary
+func (q *QuickMail) templatesDir() (string, error) {
+	dir, err := core.DataDir(q.config)
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "templates")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("could not create templates directory: %w", err)
+	}
+	return dir, nil
+}
+
+// listTemplates returns the template names, without extension, in order
+func (q *QuickMail) listTemplates() ([]string, error) {
+	dir, err := q.templatesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, file := range files {
+		if !file.IsDir() && strings.EqualFold(filepath.Ext(file.Name()), templateExt) {
+			names = append(names, strings.TrimSuffix(file.Name(), filepath.Ext(file.Name())))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// templatePath returns the file of the named template
+func (q *QuickMail) templatePath(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name != filepath.Base(name) || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("%q is not a valid template name", name)
+	}
+
+	dir, err := q.templatesDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+templateExt), nil
+}
+
+// loadTemplate inserts the named template at the cursor
+func (q *QuickMail) loadTemplate(name string) {
+	path, err := q.templatePath(name)
+	if err != nil {
+		q.showError(fmt.Sprintf("Template error: %v", err))
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		q.showError(fmt.Sprintf("Could not load template: %v", err))
+		return
+	}
+	q.insertTemplate(string(data))
+}
+
+// saveTemplate writes text as the named template
+func (q *QuickMail) saveTemplate(name, text string) error {
+	path, err := q.templatePath(name)
+	if err != nil {
+		return err
+	}
+	if err := core.WriteFileAtomic(path, []byte(text), 0600); err != nil {
+		return err
+	}
+	q.refreshTemplatesMenu()
+	return nil
+}
+
+// showLoadTemplateDialog lets the user pick a template file from the
+// templates directory and inserts it at the cursor
+func (q *QuickMail) showLoadTemplateDialog() {
+	dir, err := q.templatesDir()
+	if err != nil {
+		q.showError(fmt.Sprintf("Template error: %v", err))
+		return
+	}
+
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			q.showError(fmt.Sprintf("Could not open file: %v", err))
+			return
+		}
+		if reader == nil {
+			return
+		}
+		reader.Close()
+
+		data, err := os.ReadFile(reader.URI().Path())
+		if err != nil {
+			q.showError(fmt.Sprintf("Could not load template: %v", err))
+			return
+		}
+		q.insertTemplate(string(data))
+	}, q.window)
+
+	openDialog.SetFilter(storage.NewExtensionFileFilter([]string{templateExt}))
+	if location, err := storage.ListerForURI(storage.NewFileURI(dir)); err == nil {
+		openDialog.SetLocation(location)
+	}
+	openDialog.Show()
+}
+
+// showSaveTemplateDialog asks for a name and saves the current text as a
+// template, confirming before an existing template is replaced
+func (q *QuickMail) showSaveTemplateDialog() {
+	nameEntry := widget.NewEntry()
+	nameEntry.PlaceHolder = "Template name"
+
+	saveDialog := dialog.NewForm(
+		"Save as Template",
+		"Save",
+		"Cancel",
+		[]*widget.FormItem{
+			widget.NewFormItem("Name:", nameEntry),
+		},
+		func(confirmed bool) {
+			if !confirmed {
+				return
+			}
+
+			name := strings.TrimSpace(nameEntry.Text)
+			text := q.textArea.Text
+			save := func() {
+				if err := q.saveTemplate(name, text); err != nil {
+					q.showError(fmt.Sprintf("Could not save template: %v", err))
+				}
+			}
+
+			path, err := q.templatePath(name)
+			if err != nil {
+				q.showError(fmt.Sprintf("Template error: %v", err))
+				return
+			}
+			if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+				save()
+				return
+			}
+			dialog.ShowConfirm("Replace Template", fmt.Sprintf("Replace the template %q?", name), func(replace bool) {
+				if replace {
+					save()
+				}
+			}, q.window)
+		},
+		q.window,
+	)
+
+	saveDialog.Show()
+	saveDialog.Resize(fyne.NewSize(400, 150))
+}
+
+// newTemplatesMenu creates the Templates menu
+func (q *QuickMail) newTemplatesMenu() *fyne.Menu {
+	q.templatesMenu = fyne.NewMenu("Templates")
+	q.refreshTemplatesMenu()
+	return q.templatesMenu
+}
+
+// refreshTemplatesMenu lists the current templates in the Templates menu
+func (q *QuickMail) refreshTemplatesMenu() {
+	if q.templatesMenu == nil {
+		return
+	}
+
+	items := []*fyne.MenuItem{
+		fyne.NewMenuItem("Insert Template...", q.showLoadTemplateDialog),
+		fyne.NewMenuItem("Save Current Text as Template...", q.showSaveTemplateDialog),
+	}
+
+	names, err := q.listTemplates()
+	if err != nil {
+		fmt.Printf("Warning: Could not list templates: %v\n", err)
+	}
+	if len(names) > 0 {
+		items = append(items, fyne.NewMenuItemSeparator())
+	}
+	for _, name := range names {
+		items = append(items, fyne.NewMenuItem(name, func() {
+			q.loadTemplate(name)
+		}))
+	}
+
+	q.templatesMenu.Items = items
+	q.templatesMenu.Refresh()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpandPlaceholders(t *testing.T) {
+	values := templateValues(time.Date(2026, 3, 2, 9, 5, 0, 0, time.FixedZone("CET", 3600)), "Grüße")
+
+	tests := []struct {
+		name, text, want string
+	}{
+		{"no placeholders", "Hello,\n\nregards\n", "Hello,\n\nregards\n"},
+		{"date", "Date: {{date}}", "Date: Mon, 02 Mar 2026 09:05:00 +0100"},
+		{"time", "Sent at {{time}}", "Sent at 09:05"},
+		{"subject", "Re: {{subject}}", "Re: Grüße"},
+		{"repeated", "{{time}} {{time}}", "09:05 09:05"},
+		{"adjacent", "{{subject}}{{time}}", "Grüße09:05"},
+		{"unknown kept", "Dear {{name}},", "Dear {{name}},"},
+		{"known and unknown", "{{greeting}} at {{time}}", "{{greeting}} at 09:05"},
+		{"case-sensitive", "{{Date}}", "{{Date}}"},
+		{"spaces are not a placeholder", "{{ date }}", "{{ date }}"},
+		{"single braces", "{date}", "{date}"},
+		{"unclosed", "{{date", "{{date"},
+		{"triple braces", "{{{time}}}", "{09:05}"},
+		{"empty name", "{{}}", "{{}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandPlaceholders(tt.text, values); got != tt.want {
+				t.Errorf("expandPlaceholders(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandPlaceholdersValueNotExpanded(t *testing.T) {
+	// A subject that looks like a placeholder is inserted as it is
+	values := templateValues(time.Now(), "{{time}}")
+	if got := expandPlaceholders("Re: {{subject}}", values); got != "Re: {{time}}" {
+		t.Errorf("expandPlaceholders = %q, want %q", got, "Re: {{time}}")
+	}
+}
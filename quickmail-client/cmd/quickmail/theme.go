@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+)
+
+// appID identifies QuickMail to Fyne, which needs it to store preferences
+const appID = "io.github.ch1ffr3punk.quickmail"
+
+// darkThemeKey is the preference holding the theme chosen with the theme
+// switch
+const darkThemeKey = "darkTheme"
+
+// themePreference decides between the dark, the light and the system
+// theme. The FYNE_THEME environment variable, Fyne's own override, wins
+// over the theme of the config, which wins over the choice stored in the
+// preferences, which wins over the system variant at startup.
+type themePreference struct {
+	prefs fyne.Preferences
+
+	// configured is the theme set in the config, if any
+	configured string
+
+	// systemDark reports whether the desktop prefers a dark theme
+	systemDark func() bool
+}
+
+// newThemePreference returns the theme preference of app and config
+func newThemePreference(app fyne.App, config *core.Config) themePreference {
+	return themePreference{
+		prefs:      app.Preferences(),
+		configured: config.ThemeMode(),
+		systemDark: func() bool {
+			return app.Settings().ThemeVariant() == theme.VariantDark
+		},
+	}
+}
+
+// mode returns the theme to use, core.ThemeDark, core.ThemeLight or
+// core.ThemeSystem
+func (p themePreference) mode() string {
+	switch strings.ToLower(os.Getenv("FYNE_THEME")) {
+	case "dark":
+		return core.ThemeDark
+	case "light":
+		return core.ThemeLight
+	}
+	if p.configured != "" {
+		return p.configured
+	}
+	if p.prefs.BoolWithFallback(darkThemeKey, p.systemDark()) {
+		return core.ThemeDark
+	}
+	return core.ThemeLight
+}
+
+// setDark stores the theme chosen with the theme switch
+func (p themePreference) setDark(dark bool) {
+	p.prefs.SetBool(darkThemeKey, dark)
+}
+
+// applyTheme switches the app to the theme of mode. With core.ThemeSystem
+// Fyne's default theme follows the desktop as it changes.
+func (q *QuickMail) applyTheme(mode string) {
+	q.themeMode = mode
+	switch mode {
+	case core.ThemeDark:
+		q.app.Settings().SetTheme(theme.DarkTheme())
+	case core.ThemeLight:
+		q.app.Settings().SetTheme(theme.LightTheme())
+	default:
+		q.app.Settings().SetTheme(theme.DefaultTheme())
+	}
+	q.isDarkTheme = mode == core.ThemeDark ||
+		mode == core.ThemeSystem && q.app.Settings().ThemeVariant() == theme.VariantDark
+}
+
+// followSystemTheme keeps isDarkTheme in step with the desktop while the
+// system theme is used
+func (q *QuickMail) followSystemTheme() {
+	q.app.Settings().AddListener(func(settings fyne.Settings) {
+		if q.themeMode == core.ThemeSystem {
+			q.isDarkTheme = settings.ThemeVariant() == theme.VariantDark
+		}
+	})
+}
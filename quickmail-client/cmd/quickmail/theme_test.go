@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2/test"
+)
+
+func TestThemePreferenceMode(t *testing.T) {
+	type stored int
+	const (
+		unset stored = iota
+		storedDark
+		storedLight
+	)
+
+	tests := []struct {
+		name       string
+		env        string
+		configured string
+		stored     stored
+		systemDark bool
+		want       string
+	}{
+		{name: "system light at first start", want: core.ThemeLight},
+		{name: "system dark at first start", systemDark: true, want: core.ThemeDark},
+		{name: "stored choice wins over system", stored: storedLight, systemDark: true, want: core.ThemeLight},
+		{name: "stored dark", stored: storedDark, want: core.ThemeDark},
+		{name: "config wins over stored choice", configured: core.ThemeLight, stored: storedDark, want: core.ThemeLight},
+		{name: "config system", configured: core.ThemeSystem, stored: storedDark, want: core.ThemeSystem},
+		{name: "FYNE_THEME wins over config", env: "dark", configured: core.ThemeLight, want: core.ThemeDark},
+		{name: "FYNE_THEME is case-insensitive", env: "Light", stored: storedDark, want: core.ThemeLight},
+		{name: "unknown FYNE_THEME is ignored", env: "solarized", stored: storedDark, want: core.ThemeDark},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FYNE_THEME", tt.env)
+			app := test.NewTempApp(t)
+
+			p := themePreference{
+				prefs:      app.Preferences(),
+				configured: tt.configured,
+				systemDark: func() bool { return tt.systemDark },
+			}
+			switch tt.stored {
+			case storedDark:
+				p.setDark(true)
+			case storedLight:
+				p.setDark(false)
+			}
+
+			if got := p.mode(); got != tt.want {
+				t.Errorf("mode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThemePreferencePersists(t *testing.T) {
+	t.Setenv("FYNE_THEME", "")
+	app := test.NewTempApp(t)
+	systemLight := func() bool { return false }
+
+	first := themePreference{prefs: app.Preferences(), systemDark: systemLight}
+	first.setDark(true)
+
+	// A new preference on the same store stands for the next start
+	next := themePreference{prefs: app.Preferences(), systemDark: systemLight}
+	if got := next.mode(); got != core.ThemeDark {
+		t.Errorf("mode() after restart = %q, want %q", got, core.ThemeDark)
+	}
+	if !app.Preferences().Bool(darkThemeKey) {
+		t.Errorf("preference %q was not stored", darkThemeKey)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// tooltipDelay is how long the mouse rests on a button before its tooltip
+// is shown
+const tooltipDelay = 600 * time.Millisecond
+
+// tooltipLayer draws tooltips above the window content. Fyne has no
+// tooltips of its own, and a pop-up would swallow the next click and count
+// as an open dialog, so the layer is stacked over the content instead;
+// nothing in it handles events, so they reach the widgets below.
+type tooltipLayer struct {
+	container  *fyne.Container
+	box        *fyne.Container
+	background *canvas.Rectangle
+	label      *widget.Label
+}
+
+// newTooltipLayer creates an empty tooltip layer
+func newTooltipLayer() *tooltipLayer {
+	l := &tooltipLayer{
+		background: canvas.NewRectangle(theme.Color(theme.ColorNameOverlayBackground)),
+		label:      widget.NewLabel(""),
+	}
+	l.background.CornerRadius = theme.InputRadiusSize()
+	l.box = container.NewStack(l.background, l.label)
+	l.box.Hide()
+	l.container = container.NewWithoutLayout(l.box)
+	return l
+}
+
+// show displays text below pos, given in canvas coordinates, keeping the
+// tooltip inside the window
+func (l *tooltipLayer) show(text string, pos fyne.Position) {
+	l.label.SetText(text)
+	l.background.FillColor = theme.Color(theme.ColorNameOverlayBackground)
+	l.background.StrokeColor = theme.Color(theme.ColorNameShadow)
+	l.background.StrokeWidth = 1
+	l.background.Refresh()
+
+	size := l.box.MinSize()
+	l.box.Resize(size)
+
+	origin := fyne.CurrentApp().Driver().AbsolutePositionForObject(l.container)
+	pos = pos.Subtract(origin)
+	bounds := l.container.Size()
+	x := pos.X
+	if x+size.Width > bounds.Width {
+		x = bounds.Width - size.Width
+	}
+	y := pos.Y + 20
+	if y+size.Height > bounds.Height {
+		y = pos.Y - size.Height - 4
+	}
+	l.box.Move(fyne.NewPos(max(x, 0), max(y, 0)))
+	l.box.Show()
+}
+
+// hide removes the tooltip
+func (l *tooltipLayer) hide() {
+	l.box.Hide()
+}
+
+// tipButton is a button that shows a tooltip when the mouse rests on it
+type tipButton struct {
+	widget.Button
+
+	tip     string
+	layer   *tooltipLayer
+	timer   *time.Timer
+	hovered bool
+}
+
+// newTipButton creates a button with the given tooltip
+func newTipButton(label string, icon fyne.Resource, tip string, layer *tooltipLayer, tapped func()) *tipButton {
+	b := &tipButton{tip: tip, layer: layer}
+	b.Text = label
+	b.Icon = icon
+	b.OnTapped = tapped
+	b.ExtendBaseWidget(b)
+	return b
+}
+
+// MouseIn starts the timer that shows the tooltip
+func (b *tipButton) MouseIn(e *desktop.MouseEvent) {
+	b.Button.MouseIn(e)
+	b.hovered = true
+	b.stopTimer()
+	pos := e.AbsolutePosition
+	b.timer = time.AfterFunc(tooltipDelay, func() {
+		fyne.Do(func() {
+			if b.hovered {
+				b.layer.show(b.tip, pos)
+			}
+		})
+	})
+}
+
+// MouseOut hides the tooltip
+func (b *tipButton) MouseOut() {
+	b.Button.MouseOut()
+	b.hideTip()
+}
+
+// Tapped hides the tooltip before running the button action
+func (b *tipButton) Tapped(e *fyne.PointEvent) {
+	b.hideTip()
+	b.Button.Tapped(e)
+}
+
+// hideTip stops a pending tooltip and hides a shown one
+func (b *tipButton) hideTip() {
+	b.hovered = false
+	b.stopTimer()
+	b.layer.hide()
+}
+
+// stopTimer cancels a pending tooltip
+func (b *tipButton) stopTimer() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+}
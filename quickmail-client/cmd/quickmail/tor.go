@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"quickmail/core"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+	"golang.org/x/net/proxy"
+)
+
+// resolveTorProxy returns the cached Tor proxy, detecting it on first use
+// with the settings of config. With proxy_type direct there is no proxy
+// and it returns "".
+func (q *QuickMail) resolveTorProxy(config *core.Config) (string, error) {
+	q.torProxyMu.Lock()
+	defer q.torProxyMu.Unlock()
+
+	if q.torProxy != "" {
+		return q.torProxy, nil
+	}
+
+	protocol := config.ProxyProtocol()
+	if protocol == core.ProxyDirect {
+		return "", nil
+	}
+
+	configured := ""
+	if config != nil {
+		configured = config.TorProxy
+	}
+
+	address, err := core.DetectTorProxy(configured, protocol, proxyAuth(config) != nil)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Printf("Using Tor proxy %s\n", address)
+	q.torProxy = address
+	return address, nil
+}
+
+// cachedTorProxy returns the proxy selected by resolveTorProxy, if any
+func (q *QuickMail) cachedTorProxy() string {
+	q.torProxyMu.Lock()
+	defer q.torProxyMu.Unlock()
+	return q.torProxy
+}
+
+// resetTorProxy forgets the cached proxy so the next send probes again
+func (q *QuickMail) resetTorProxy() {
+	q.torProxyMu.Lock()
+	defer q.torProxyMu.Unlock()
+	q.torProxy = ""
+}
+
+// proxyAuth returns the proxy credentials from config, or nil if none are
+// configured
+func proxyAuth(config *core.Config) *proxy.Auth {
+	if config == nil || config.ProxyUser == "" {
+		return nil
+	}
+	return &proxy.Auth{
+		User:     config.ProxyUser,
+		Password: config.ProxyPass,
+	}
+}
+
+// torClient returns an HTTP client that connects through the Tor proxy
+// with the configured send timeout. With use_tls, HTTPS connections trust
+// the configured CA certificate.
+func (q *QuickMail) torClient() (*http.Client, error) {
+	config := q.currentConfig()
+	return q.newTorClient(config, proxyAuth(config))
+}
+
+// sendClient returns the HTTP client for uploading one message with the
+// settings of config. With
+// isolate_streams, and no proxy_user whose credentials must be sent, it
+// authenticates with random credentials so the message leaves on a Tor
+// circuit of its own.
+//
+// Isolation relies on every upload getting a new transport, as it does
+// here, so no connection is kept alive from one message to the next. If
+// transports are ever pooled, isolated uploads must not share one: a
+// reused connection would carry the next message over the previous
+// circuit.
+func (q *QuickMail) sendClient(config *core.Config) (*http.Client, error) {
+	auth := proxyAuth(config)
+	if auth == nil && config.ShouldIsolateStreams() && config.ProxyProtocol() != core.ProxyDirect {
+		var err error
+		if auth, err = core.NewIsolationAuth(); err != nil {
+			return nil, err
+		}
+	}
+	return q.newTorClient(config, auth)
+}
+
+// newTorClient returns an HTTP client that connects through the Tor proxy
+// with the settings of config, authenticating with auth if it is not nil
+func (q *QuickMail) newTorClient(config *core.Config, auth *proxy.Auth) (*http.Client, error) {
+	torProxy, err := q.resolveTorProxy(config)
+	if err != nil {
+		return nil, err
+	}
+	return core.NewTorClient(torProxy, auth, config)
+}
+
+// newTorLabel creates the label showing whether Tor is reachable
+func (q *QuickMail) newTorLabel() *widget.Label {
+	q.torLabel = widget.NewLabel("Tor not checked")
+	return q.torLabel
+}
+
+// checkTor probes the Tor proxy in the background, optionally confirms
+// the circuit, and enables the Send button if Tor is reachable. With
+// proxy_type direct there is nothing to check and the label warns that Tor
+// is not used.
+func (q *QuickMail) checkTor() {
+	if q.config.ProxyProtocol() == core.ProxyDirect {
+		q.resetTorProxy()
+		q.torReady.Store(true)
+		q.setTorStatus("Direct, no Tor", widget.WarningImportance)
+		return
+	}
+
+	q.torReady.Store(false)
+	q.setTorStatus("Checking Tor...", widget.MediumImportance)
+
+	config := q.currentConfig()
+	go func() {
+		q.resetTorProxy()
+		torProxy, err := q.resolveTorProxy(config)
+		if err == nil && config != nil && config.CheckTorCircuit {
+			err = core.CheckTorCircuit(torProxy, config.ProxyProtocol(), proxyAuth(config))
+		}
+
+		if err != nil {
+			fmt.Printf("Tor check failed: %v\n", err)
+			q.setTorStatus("Tor unreachable", widget.DangerImportance)
+			return
+		}
+		q.torReady.Store(true)
+		q.setTorStatus("Tor OK", widget.SuccessImportance)
+	}()
+}
+
+// setTorStatus updates the Tor label and the Send button; it may be
+// called from any goroutine
+func (q *QuickMail) setTorStatus(text string, importance widget.Importance) {
+	if q.torLabel == nil {
+		return
+	}
+	fyne.Do(func() {
+		q.torLabel.Importance = importance
+		q.torLabel.SetText(text)
+		q.updateSendEnabled()
+	})
+}
+
+// routeDescription tells how the last message left the client, for the
+// send result
+func (q *QuickMail) routeDescription() string {
+	if q.config.ProxyProtocol() == core.ProxyDirect {
+		return "directly, without Tor"
+	}
+	return "via Tor proxy " + q.cachedTorProxy()
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+// defaultUndoLevels is the number of text states kept when the config
+// does not set undo_levels
+const defaultUndoLevels = 50
+
+// undoDebounce is how long typing must pause before the text is recorded
+const undoDebounce = 500 * time.Millisecond
+
+// EditHistory keeps up to max snapshots of the message text for undo and
+// redo. When it is full the oldest snapshot is dropped.
+type EditHistory struct {
+	states []string
+	pos    int
+	max    int
+}
+
+// NewEditHistory returns a history holding up to max states, starting
+// with text
+func NewEditHistory(text string, max int) *EditHistory {
+	if max < 2 {
+		max = 2
+	}
+	return &EditHistory{states: []string{text}, max: max}
+}
+
+// Record adds text as the newest state, discarding the states that could
+// have been redone
+func (h *EditHistory) Record(text string) {
+	if h.states[h.pos] == text {
+		return
+	}
+	h.states = append(h.states[:h.pos+1], text)
+	if len(h.states) > h.max {
+		h.states = h.states[len(h.states)-h.max:]
+	}
+	h.pos = len(h.states) - 1
+}
+
+// Undo steps back and returns the previous state, or false if there is
+// none
+func (h *EditHistory) Undo() (string, bool) {
+	if h.pos == 0 {
+		return "", false
+	}
+	h.pos--
+	return h.states[h.pos], true
+}
+
+// Redo steps forward and returns the next state, or false if there is none
+func (h *EditHistory) Redo() (string, bool) {
+	if h.pos == len(h.states)-1 {
+		return "", false
+	}
+	h.pos++
+	return h.states[h.pos], true
+}
+
+// Reset forgets all states and starts again with text
+func (h *EditHistory) Reset(text string) {
+	h.states = []string{text}
+	h.pos = 0
+}
+
+// newEditHistory creates the edit history of the text area
+func (q *QuickMail) newEditHistory() {
+	levels := defaultUndoLevels
+	if q.config != nil && q.config.UndoLevels > 0 {
+		levels = q.config.UndoLevels
+	}
+	q.editHistory = NewEditHistory(q.textArea.Text, levels)
+}
+
+// recordEdit records the text in the edit history once typing pauses for
+// undoDebounce
+func (q *QuickMail) recordEdit() {
+	if q.editHistory == nil || q.applyingHistory {
+		return
+	}
+	if q.editTimer != nil {
+		q.editTimer.Stop()
+	}
+	q.editTimer = time.AfterFunc(undoDebounce, func() {
+		fyne.Do(func() {
+			q.editHistory.Record(q.textArea.Text)
+		})
+	})
+}
+
+// setHistoryText shows a state from the edit history without recording it
+// again
+func (q *QuickMail) setHistoryText(text string) {
+	q.applyingHistory = true
+	q.textArea.SetText(text)
+	q.applyingHistory = false
+}
+
+// undo restores the previous state of the text area
+func (q *QuickMail) undo() {
+	if q.editHistory == nil {
+		return
+	}
+	// Record pending typing first, so undo returns to before it
+	if q.editTimer != nil && q.editTimer.Stop() {
+		q.editHistory.Record(q.textArea.Text)
+	}
+	if text, ok := q.editHistory.Undo(); ok {
+		q.setHistoryText(text)
+	}
+}
+
+// redo restores the state undone last
+func (q *QuickMail) redo() {
+	if q.editHistory == nil {
+		return
+	}
+	if text, ok := q.editHistory.Redo(); ok {
+		q.setHistoryText(text)
+	}
+}
+
+// resetEditHistory clears the undo and redo states, as after Send or
+// Clear
+func (q *QuickMail) resetEditHistory() {
+	if q.editHistory == nil {
+		return
+	}
+	if q.editTimer != nil {
+		q.editTimer.Stop()
+	}
+	q.editHistory.Reset(q.textArea.Text)
+}
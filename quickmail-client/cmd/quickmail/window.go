@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+)
+
+// Preference keys holding the main window size from the last session
+const (
+	windowWidthKey  = "windowWidth"
+	windowHeightKey = "windowHeight"
+)
+
+// defaultWindowSize is used on first launch and when the stored size is
+// unusable
+var defaultWindowSize = fyne.NewSize(800, 600)
+
+// Stored sizes outside these bounds are ignored, for example after a
+// monitor with a larger resolution was disconnected
+const (
+	minWindowSide = 300
+	maxWindowSide = 8192
+)
+
+// restoreWindowSize resizes the main window to the size stored when it was
+// last closed and centers it. Fyne cannot place a window at a given
+// position, so only the size is restored; centering keeps the window on
+// the current screen.
+func (q *QuickMail) restoreWindowSize() {
+	prefs := q.app.Preferences()
+	size := fyne.NewSize(
+		float32(prefs.FloatWithFallback(windowWidthKey, float64(defaultWindowSize.Width))),
+		float32(prefs.FloatWithFallback(windowHeightKey, float64(defaultWindowSize.Height))),
+	)
+	if !usableWindowSize(size) {
+		size = defaultWindowSize
+	}
+
+	q.window.Resize(size)
+	q.window.CenterOnScreen()
+}
+
+// saveWindowSize stores the size of the main window for the next launch
+func (q *QuickMail) saveWindowSize() {
+	size := q.window.Canvas().Size()
+	if !usableWindowSize(size) {
+		return
+	}
+
+	prefs := q.app.Preferences()
+	prefs.SetFloat(windowWidthKey, float64(size.Width))
+	prefs.SetFloat(windowHeightKey, float64(size.Height))
+}
+
+// usableWindowSize reports whether both sides of size are within bounds
+func usableWindowSize(size fyne.Size) bool {
+	return size.Width >= minWindowSide && size.Width <= maxWindowSide &&
+		size.Height >= minWindowSide && size.Height <= maxWindowSide
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+// wordWrapKey is the preference holding whether the message entry wraps
+// long lines
+const wordWrapKey = "wordWrap"
+
+// newWrapButton creates the top bar button switching word wrap, which is
+// highlighted while long lines are wrapped
+func (q *QuickMail) newWrapButton() *tipButton {
+	button := newTipButton("Wrap", nil, q.shortcutTip("Toggle Word Wrap"), q.tooltips, q.toggleWordWrap)
+	q.wrapButton = &button.Button
+	q.applyWordWrap(q.app.Preferences().BoolWithFallback(wordWrapKey, true))
+	return button
+}
+
+// applyWordWrap wraps long lines of the message entry at word boundaries,
+// or shows them unwrapped so their actual length is visible
+func (q *QuickMail) applyWordWrap(wrap bool) {
+	if wrap {
+		q.textArea.Wrapping = fyne.TextWrapWord
+	} else {
+		q.textArea.Wrapping = fyne.TextWrapOff
+	}
+	q.textArea.Refresh()
+	q.updateLineNumbers()
+
+	if q.wrapButton != nil {
+		q.wrapButton.Importance = widget.LowImportance
+		if wrap {
+			q.wrapButton.Importance = widget.MediumImportance
+		}
+		q.wrapButton.Refresh()
+	}
+}
+
+// toggleWordWrap switches word wrap of the message entry and remembers the
+// choice for the next start
+func (q *QuickMail) toggleWordWrap() {
+	wrap := q.textArea.Wrapping == fyne.TextWrapOff
+	q.applyWordWrap(wrap)
+	q.app.Preferences().SetBool(wordWrapKey, wrap)
+}
@@ -0,0 +1,863 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"mime"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTorProxy is the SOCKS5 address of a system Tor daemon
+const DefaultTorProxy = "127.0.0.1:9050"
+
+// Proxy types accepted in proxy_type. ProxyDirect bypasses Tor and is only
+// meant for testing against a local server.
+const (
+	ProxySOCKS5  = "socks5"
+	ProxySOCKS4a = "socks4a"
+	ProxyDirect  = "direct"
+)
+
+// Themes accepted in theme. ThemeSystem follows the light or dark
+// appearance of the desktop, also when it changes.
+const (
+	ThemeDark   = "dark"
+	ThemeLight  = "light"
+	ThemeSystem = "system"
+)
+
+// BccHeader carries the Bcc recipients to the server outside the message
+const BccHeader = "X-QuickMail-Bcc"
+
+// DefaultTimeout is the timeout of requests without a payload, and the base
+// of the send timeout when the config does not set one
+const DefaultTimeout = 30 * time.Second
+
+// DefaultConnectTimeout bounds connecting to the server through the proxy,
+// which includes building the Tor circuit, when the config does not set one
+const DefaultConnectTimeout = 60 * time.Second
+
+// minTorThroughput is the upload rate in bytes per second of a slow but
+// working Tor circuit, which the automatic send timeout allows for
+const minTorThroughput = 16 << 10
+
+// minSendTimeoutSeconds is the shortest send timeout the config accepts,
+// since anything shorter cannot even build a Tor circuit
+const minSendTimeoutSeconds = 5
+
+// DefaultProfileName names the profile created from the old single-server format
+const DefaultProfileName = "default"
+
+// ServerProfile describes one QuickMail server the client can send to
+type ServerProfile struct {
+	Name         string `json:"name"`
+	OnionAddress string `json:"onion_address"`
+	Port         string `json:"port"`
+}
+
+// BaseURL returns the URL of this server, including the path of the
+// address if it has one. Addresses without a scheme use http, or https
+// for clearnet hosts when useTLS is set. The port field is used unless the
+// address names a port itself.
+func (s *ServerProfile) BaseURL(useTLS bool) string {
+	serverAddress := strings.TrimSpace(s.OnionAddress)
+	if !strings.HasPrefix(serverAddress, "http://") && !strings.HasPrefix(serverAddress, "https://") {
+		if useTLS && !IsOnionHost(serverAddress) {
+			serverAddress = "https://" + serverAddress
+		} else {
+			serverAddress = "http://" + serverAddress
+		}
+	}
+
+	u, err := url.Parse(serverAddress)
+	if err != nil {
+		return serverAddress
+	}
+	if s.Port != "" && u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), s.Port)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	return u.String()
+}
+
+// UploadURL returns the URL messages are posted to on this server
+func (s *ServerProfile) UploadURL(useTLS bool) string {
+	return s.BaseURL(useTLS) + "/upload"
+}
+
+// ErrNoServerAddress is returned by CheckAddress for a profile whose
+// onion_address has not been filled in, like the one of the first-run
+// config
+var ErrNoServerAddress = errors.New("no onion_address set")
+
+// CheckAddress returns an error wrapping ErrNoServerAddress if there is no
+// address to send to on this server yet
+func (s *ServerProfile) CheckAddress() error {
+	if strings.TrimSpace(s.OnionAddress) == "" {
+		return fmt.Errorf("server profile %q has %w", s.Name, ErrNoServerAddress)
+	}
+	return nil
+}
+
+// RetryPolicy controls how often and how fast failed uploads are retried
+type RetryPolicy struct {
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	BaseDelayMs int `json:"base_delay_ms"`
+	JitterMs    int `json:"jitter_ms"`
+}
+
+// defaultMaxRetries is the number of retries after the first attempt
+const defaultMaxRetries = 3
+
+// DefaultRetryPolicy is used when the config file has no retry section
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: defaultMaxRetries + 1,
+	BaseDelayMs: 2000,
+	JitterMs:    1000,
+}
+
+// maxRetryDelay caps the backoff, which would otherwise grow without
+// bound, and overflow, with a large max_retries
+const maxRetryDelay = 5 * time.Minute
+
+// Delay returns the backoff before the given retry (1 for the first retry),
+// at most maxRetryDelay
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	d := maxRetryDelay
+	if int64(p.BaseDelayMs) < maxRetryDelay.Milliseconds() {
+		d = time.Duration(p.BaseDelayMs) * time.Millisecond
+	}
+	for i := 1; i < retry && d > 0 && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	if p.JitterMs > 0 {
+		jitter := min(int64(p.JitterMs), maxRetryDelay.Milliseconds())
+		d += time.Duration(rand.Int64N(jitter)) * time.Millisecond
+	}
+	return min(d, maxRetryDelay)
+}
+
+// Config structure for the configuration file
+type Config struct {
+	Servers        []ServerProfile `json:"servers,omitempty"`
+	DefaultProfile string          `json:"default_profile,omitempty"`
+	ActiveServer   string          `json:"active_server,omitempty"`
+	TorProxy       string          `json:"tor_proxy,omitempty"`
+	Retry          *RetryPolicy    `json:"retry,omitempty"`
+
+	// SocksProxy is accepted as an alias for TorProxy
+	SocksProxy string `json:"socks_proxy,omitempty"`
+
+	// ProxyType is the protocol spoken with TorProxy: ProxySOCKS5, the
+	// default, or ProxySOCKS4a. ProxyDirect connects without a proxy.
+	ProxyType string `json:"proxy_type,omitempty"`
+
+	// ProxyUser and ProxyPass authenticate to the SOCKS5 proxy, which
+	// shared Tor gateways may require; without ProxyUser no credentials
+	// are sent
+	ProxyUser string `json:"proxy_user,omitempty"`
+	ProxyPass string `json:"proxy_pass,omitempty"`
+
+	// SendTimeoutSeconds limits each upload attempt; 0 disables the
+	// timeout, leaving it unset picks one from the size of the message and
+	// any other value must be at least minSendTimeoutSeconds
+	SendTimeoutSeconds *int `json:"send_timeout_seconds,omitempty"`
+
+	// TimeoutSeconds is accepted as an alias for SendTimeoutSeconds
+	TimeoutSeconds *int `json:"timeout_seconds,omitempty"`
+
+	// ConnectTimeoutSeconds limits connecting to the server through the
+	// proxy, SOCKS handshake included; 0 uses DefaultConnectTimeout
+	ConnectTimeoutSeconds int `json:"connect_timeout_seconds,omitempty"`
+
+	// MaxRetries is the number of retries after a failed first attempt and
+	// overrides Retry.MaxAttempts; leaving it unset uses defaultMaxRetries
+	MaxRetries *int `json:"max_retries,omitempty"`
+
+	// SigningKeyPath points to an armored secret key used by the Sign option
+	SigningKeyPath string `json:"signing_key_path,omitempty"`
+
+	// RecipientKeyPath points to the armored public key used by the
+	// Encrypt option
+	RecipientKeyPath string `json:"recipient_key_path,omitempty"`
+
+	// AgeRecipientsPath points to a file with one age public key per line
+	// used by Encrypt (age), and AgeIdentityPath to the age identity file
+	// used by Decrypt (age)
+	AgeRecipientsPath string `json:"age_recipients_path,omitempty"`
+	AgeIdentityPath   string `json:"age_identity_path,omitempty"`
+
+	// UndoLevels is the number of text states kept for undo; 0 uses the
+	// client default
+	UndoLevels int `json:"undo_levels,omitempty"`
+
+	// Drafts autosaves the message being composed so it can be restored
+	// after a crash; leaving it unset saves drafts. DraftIntervalSeconds is
+	// the autosave interval; 0 uses the client default.
+	Drafts               *bool `json:"drafts,omitempty"`
+	DraftIntervalSeconds int   `json:"draft_interval_seconds,omitempty"`
+
+	// FontSize is the text size of the message entry; 0 uses the client
+	// default
+	FontSize float32 `json:"font_size,omitempty"`
+
+	// Theme is ThemeDark, ThemeLight or ThemeSystem; without it the theme
+	// chosen with the theme switch is used
+	Theme string `json:"theme,omitempty"`
+
+	// LastFrom is the From: address last entered in the header dialog
+	LastFrom string `json:"last_from,omitempty"`
+
+	// InboxPath is the server endpoint listing received messages; empty
+	// uses the client default
+	InboxPath string `json:"inbox_path,omitempty"`
+
+	// PollMinutes is the interval at which the inbox is polled for new
+	// messages; 0 disables polling
+	PollMinutes int `json:"poll_minutes,omitempty"`
+
+	// CheckTorCircuit makes the Tor check also fetch check.torproject.org
+	// through the proxy to confirm a working circuit
+	CheckTorCircuit bool `json:"check_tor_circuit,omitempty"`
+
+	// IsolateStreams sends every message over its own Tor circuit by
+	// giving each upload random proxy credentials; leaving it unset
+	// isolates. It has no effect while ProxyUser is set.
+	IsolateStreams *bool `json:"isolate_streams,omitempty"`
+
+	// DeleteAfterDownload removes a message from the server once it has
+	// been downloaded and stored in the local maildir
+	DeleteAfterDownload bool `json:"delete_after_download,omitempty"`
+
+	// UseTLS reaches servers that are not onion services over HTTPS, still
+	// through the Tor proxy. TLSCACert is a PEM file with the CA that
+	// signed the server certificate, for example a self-signed one; when
+	// it is set no other CA is trusted.
+	UseTLS    bool   `json:"use_tls,omitempty"`
+	TLSCACert string `json:"tls_ca_cert,omitempty"`
+
+	// SubjectEncoding selects the RFC 2047 encoding of non-ASCII subjects:
+	// "B" for base64, the default, or "Q" for quoted-printable, which keeps
+	// mostly ASCII subjects readable
+	SubjectEncoding string `json:"subject_encoding,omitempty"`
+
+	// ClipboardClearSeconds clears the clipboard this long after text was
+	// last copied from the message, if it still holds that text; 0
+	// disables it and leaving it unset uses defaultClipboardClearSeconds
+	ClipboardClearSeconds *int `json:"clipboard_clear_seconds,omitempty"`
+
+	// ConfirmSend asks before a message is sent, showing the server and
+	// the message size, and ConfirmClear asks before Clear discards a
+	// non-empty message; leaving them unset asks
+	ConfirmSend  *bool `json:"confirm_send,omitempty"`
+	ConfirmClear *bool `json:"confirm_clear,omitempty"`
+
+	// MaxMessageBytes marks the stats label red when the composition grows
+	// beyond it and asks for confirmation before sending a larger message;
+	// 0 means no limit
+	MaxMessageBytes int `json:"max_message_bytes,omitempty"`
+
+	// MaxAttachmentBytes limits the total size of the files attached to a
+	// message; 0 means no limit
+	MaxAttachmentBytes int `json:"max_attachment_bytes,omitempty"`
+
+	// BatchContinueOnError keeps Batch Send going after a file failed to
+	// send; by default the batch stops at the first failure
+	BatchContinueOnError bool `json:"batch_continue_on_error,omitempty"`
+
+	// Profiles is an alternative to Servers keyed by profile name, which
+	// LoadConfig merges into Servers
+	Profiles map[string]ServerProfile `json:"profiles,omitempty"`
+
+	// OnionAddress and Port are the old single-server format, which
+	// LoadConfig converts into a one-element Servers list
+	OnionAddress string `json:"onion_address,omitempty"`
+	Port         string `json:"port,omitempty"`
+
+	// path is the file the config was loaded from or last saved to
+	path string
+
+	// warnings collects problems LoadConfig could work around
+	warnings []string
+}
+
+// Path returns the file the config was loaded from or last saved to
+func (c *Config) Path() string {
+	return c.path
+}
+
+// Warnings returns the problems LoadConfig found in the config file that
+// did not stop it from loading, for the caller to show
+func (c *Config) Warnings() []string {
+	return c.warnings
+}
+
+// Timeout returns the HTTP client timeout for requests without a payload,
+// where 0 means no timeout
+func (c *Config) Timeout() time.Duration {
+	return c.SendTimeout(0)
+}
+
+// SendTimeout returns the HTTP client timeout for uploading size bytes,
+// where 0 means no timeout. Unless one is configured it is DefaultTimeout
+// plus the time size bytes take at minTorThroughput.
+func (c *Config) SendTimeout(size int) time.Duration {
+	if c == nil || c.SendTimeoutSeconds == nil {
+		return DefaultTimeout + time.Duration(size/minTorThroughput)*time.Second
+	}
+	return time.Duration(*c.SendTimeoutSeconds) * time.Second
+}
+
+// ConnectTimeout returns the time allowed for connecting to the server
+// through the proxy
+func (c *Config) ConnectTimeout() time.Duration {
+	if c == nil || c.ConnectTimeoutSeconds <= 0 {
+		return DefaultConnectTimeout
+	}
+	return time.Duration(c.ConnectTimeoutSeconds) * time.Second
+}
+
+// WordEncoder returns the configured subject encoding
+func (c *Config) WordEncoder() mime.WordEncoder {
+	if c != nil && strings.EqualFold(c.SubjectEncoding, "Q") {
+		return mime.QEncoding
+	}
+	return mime.BEncoding
+}
+
+// RetryPolicy returns the configured retry policy with defaults applied
+func (c *Config) RetryPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy
+	if c.Retry != nil {
+		policy = *c.Retry
+		if policy.MaxAttempts == 0 {
+			policy.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+		}
+	}
+	if c.MaxRetries != nil {
+		policy.MaxAttempts = *c.MaxRetries + 1
+	}
+
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.BaseDelayMs < 0 {
+		policy.BaseDelayMs = 0
+	}
+	if policy.JitterMs < 0 {
+		policy.JitterMs = 0
+	}
+	return policy
+}
+
+// Profile returns the server profile with the given name or nil
+func (c *Config) Profile(name string) *ServerProfile {
+	for i := range c.Servers {
+		if c.Servers[i].Name == name {
+			return &c.Servers[i]
+		}
+	}
+	return nil
+}
+
+// ProfileNames returns the names of all server profiles in config order
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Servers))
+	for _, server := range c.Servers {
+		names = append(names, server.Name)
+	}
+	return names
+}
+
+// InitialProfile returns the name of the profile to select on startup: the
+// last used one, the configured default or the first one
+func (c *Config) InitialProfile() string {
+	if c.Profile(c.ActiveServer) != nil {
+		return c.ActiveServer
+	}
+	if c.Profile(c.DefaultProfile) != nil {
+		return c.DefaultProfile
+	}
+	if len(c.Servers) > 0 {
+		return c.Servers[0].Name
+	}
+	return ""
+}
+
+// configFileName is the name of the configuration file
+const configFileName = "quickmail.json"
+
+// ExeConfigPath returns the location of quickmail.json next to the executable
+func ExeConfigPath() (string, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(filepath.Dir(exePath), configFileName), nil
+}
+
+// UserConfigPath returns the location of quickmail.json in the user's
+// configuration directory, e.g. ~/.config/quickmail/quickmail.json
+func UserConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "quickmail", configFileName), nil
+}
+
+// configCandidates returns the locations searched for quickmail.json in
+// order: next to the executable, the user config directory and the
+// current working directory
+func configCandidates() []string {
+	var candidates []string
+	if path, err := ExeConfigPath(); err == nil {
+		candidates = append(candidates, path)
+	}
+	if path, err := UserConfigPath(); err == nil {
+		candidates = append(candidates, path)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		candidates = append(candidates, filepath.Join(wd, configFileName))
+	}
+	return candidates
+}
+
+// ErrNoConfig is returned by LoadConfig and ReadConfig when no
+// quickmail.json exists
+var ErrNoConfig = errors.New("no " + configFileName + " found")
+
+// LoadConfig loads the configuration from the first quickmail.json found
+// and validates it
+func LoadConfig() (*Config, error) {
+	config, err := ReadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ReadConfig reads the first quickmail.json found and converts the old
+// formats and aliases like LoadConfig, but does not validate it, so the
+// caller can change settings before calling Validate
+func ReadConfig() (*Config, error) {
+	candidates := configCandidates()
+
+	var path string
+	var data []byte
+	for _, candidate := range candidates {
+		content, err := os.ReadFile(candidate)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read config file %s: %w", candidate, err)
+		}
+		path = candidate
+		data = content
+		break
+	}
+	if path == "" {
+		return nil, fmt.Errorf("%w (searched %s)", ErrNoConfig, strings.Join(candidates, ", "))
+	}
+
+	var config Config
+	err := json.Unmarshal(data, &config)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse config file %s: %w", path, err)
+	}
+	config.path = path
+
+	// Convert the old single-server format into a profile list
+	if len(config.Servers) == 0 && config.OnionAddress != "" {
+		config.Servers = []ServerProfile{{
+			Name:         DefaultProfileName,
+			OnionAddress: config.OnionAddress,
+			Port:         config.Port,
+		}}
+	}
+	config.OnionAddress = ""
+	config.Port = ""
+
+	// Merge profiles given as a map, in name order
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if config.Profile(name) != nil {
+			config.warnings = append(config.warnings,
+				fmt.Sprintf("profile %q is defined twice in %s, ignoring the second definition", name, path))
+			continue
+		}
+		server := config.Profiles[name]
+		server.Name = name
+		config.Servers = append(config.Servers, server)
+	}
+	config.Profiles = nil
+
+	if config.DefaultProfile != "" && config.Profile(config.DefaultProfile) == nil {
+		config.warnings = append(config.warnings,
+			fmt.Sprintf("default_profile %q in %s names a missing profile", config.DefaultProfile, path))
+	}
+
+	if config.TorProxy == "" {
+		config.TorProxy = config.SocksProxy
+	}
+	config.SocksProxy = ""
+
+	if config.SendTimeoutSeconds == nil {
+		config.SendTimeoutSeconds = config.TimeoutSeconds
+	}
+	config.TimeoutSeconds = nil
+
+	return &config, nil
+}
+
+// Validate checks the settings of the config. Errors name the file the
+// config was read from, if any.
+func (c *Config) Validate() error {
+	source := "the configuration"
+	if c.path != "" {
+		source = "config file " + c.path
+	}
+
+	for _, server := range c.Servers {
+		// A profile without an address still loads, so the first-run
+		// config starts, and fails in CheckAddress when it is used
+		if strings.TrimSpace(server.OnionAddress) != "" {
+			if err := ValidateServerAddress(server.OnionAddress); err != nil {
+				return fmt.Errorf("invalid onion_address of profile %q in %s: %w", server.Name, source, err)
+			}
+		}
+		if err := ValidatePort(server.Port); err != nil {
+			return fmt.Errorf("invalid port of profile %q in %s: %w", server.Name, source, err)
+		}
+	}
+
+	if c.TorProxy != "" {
+		if err := ValidateProxyAddress(c.TorProxy); err != nil {
+			return fmt.Errorf("invalid tor_proxy in %s: %w", source, err)
+		}
+	}
+
+	switch strings.ToLower(c.ProxyType) {
+	case "", ProxySOCKS5, ProxySOCKS4a, ProxyDirect:
+	default:
+		return fmt.Errorf("invalid proxy_type in %s: must be %q, %q or %q", source, ProxySOCKS5, ProxySOCKS4a, ProxyDirect)
+	}
+
+	if timeout := c.SendTimeoutSeconds; timeout != nil {
+		if err := ValidateSendTimeout(*timeout); err != nil {
+			return fmt.Errorf("invalid send_timeout_seconds in %s: %w", source, err)
+		}
+	}
+	if c.ConnectTimeoutSeconds < 0 {
+		return fmt.Errorf("invalid connect_timeout_seconds in %s: must not be negative", source)
+	}
+	if c.UseTLS && c.TLSCACert != "" {
+		if _, err := NewTLSConfig(c.TLSCACert); err != nil {
+			return fmt.Errorf("invalid tls_ca_cert in %s: %w", source, err)
+		}
+	}
+	switch strings.ToUpper(c.SubjectEncoding) {
+	case "", "B", "Q":
+	default:
+		return fmt.Errorf("invalid subject_encoding in %s: must be \"B\" or \"Q\"", source)
+	}
+	if clear := c.ClipboardClearSeconds; clear != nil && *clear < 0 {
+		return fmt.Errorf("invalid clipboard_clear_seconds in %s: must not be negative", source)
+	}
+	switch strings.ToLower(c.Theme) {
+	case "", ThemeDark, ThemeLight, ThemeSystem:
+	default:
+		return fmt.Errorf("invalid theme in %s: must be %q, %q or %q", source, ThemeDark, ThemeLight, ThemeSystem)
+	}
+
+	return nil
+}
+
+// DefaultConfig returns the config of a first run, with one server profile
+// whose address is left empty to be filled in
+func DefaultConfig() *Config {
+	maxRetries := defaultMaxRetries
+	return &Config{
+		Servers: []ServerProfile{{
+			Name:         DefaultProfileName,
+			OnionAddress: "",
+			Port:         "8088",
+		}},
+		DefaultProfile: DefaultProfileName,
+		TorProxy:       DefaultTorProxy,
+		MaxRetries:     &maxRetries,
+	}
+}
+
+// CreateDefaultConfig writes the DefaultConfig to a new quickmail.json next
+// to the executable, or in the user config directory if that is not
+// writable. JSON has no comments, so the template is a plain config.
+// LoadConfig accepts its empty address, so the file still loads if the
+// first-run settings dialog is closed, and sending fails until it is set.
+func CreateDefaultConfig() (*Config, error) {
+	config := DefaultConfig()
+	if err := SaveConfig(config); err != nil {
+		return nil, fmt.Errorf("could not create %s: %w", configFileName, err)
+	}
+	return config, nil
+}
+
+// dirWritable reports whether new files can be created in dir
+func dirWritable(dir string) bool {
+	f, err := os.CreateTemp(dir, ".quickmail-probe-*")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
+
+// SaveConfig writes the configuration atomically to the file it was loaded
+// from, creating the file if it does not exist yet. If that directory is
+// not writable the user config directory is used instead.
+func SaveConfig(config *Config) error {
+	path := config.path
+	if path == "" {
+		exePath, err := ExeConfigPath()
+		if err != nil {
+			return err
+		}
+		path = exePath
+	}
+
+	if !dirWritable(filepath.Dir(path)) {
+		userPath, err := UserConfigPath()
+		if err != nil {
+			return fmt.Errorf("%s is not writable and no user config directory is available: %w", filepath.Dir(path), err)
+		}
+		if err := os.MkdirAll(filepath.Dir(userPath), 0700); err != nil {
+			return fmt.Errorf("could not create config directory: %w", err)
+		}
+		path = userPath
+	}
+
+	data, err := json.MarshalIndent(config, "", "    ")
+	if err != nil {
+		return fmt.Errorf("could not encode config: %w", err)
+	}
+	data = append(data, '\n')
+
+	if err := WriteFileAtomic(path, data, 0600); err != nil {
+		return err
+	}
+	config.path = path
+
+	return nil
+}
+
+// WriteFileAtomic writes data to a temporary file in the same directory and
+// renames it over path, so a crash never leaves a half-written file behind
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return fmt.Errorf("could not create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return fmt.Errorf("could not set permissions of %s: %w", path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not replace %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// IsOnionHost reports whether address, with or without scheme, names an
+// onion service
+func IsOnionHost(address string) bool {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	u, err := url.Parse(address)
+	return err == nil && strings.HasSuffix(strings.ToLower(u.Hostname()), ".onion")
+}
+
+// ValidateServerAddress checks that the server address is an http:// or
+// https:// URL, or a host name taken as http://, optionally with a port and
+// a path. Onion services and clearnet hosts are both reached through the
+// proxy.
+func ValidateServerAddress(address string) error {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return errors.New("server address is empty")
+	}
+
+	if scheme, _, ok := strings.Cut(address, "://"); ok && scheme != "http" && scheme != "https" {
+		return fmt.Errorf("server address must start with http:// or https://, not %s://", scheme)
+	}
+	if !strings.HasPrefix(address, "http://") && !strings.HasPrefix(address, "https://") {
+		address = "http://" + address
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return fmt.Errorf("invalid server address: %w", err)
+	}
+	if u.Hostname() == "" {
+		return errors.New("server address has no host name")
+	}
+	if u.Port() != "" {
+		if err := ValidatePort(u.Port()); err != nil {
+			return fmt.Errorf("invalid port in server address: %w", err)
+		}
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return errors.New("server address must not contain a query or fragment")
+	}
+
+	return nil
+}
+
+// ValidateProxyAddress checks that address has the form host:port or
+// unix:/path/to/socket
+func ValidateProxyAddress(address string) error {
+	if path, ok := strings.CutPrefix(strings.TrimSpace(address), unixProxyPrefix); ok {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("proxy socket path %q must be absolute", path)
+		}
+		return nil
+	}
+
+	host, port, err := net.SplitHostPort(strings.TrimSpace(address))
+	if err != nil {
+		return fmt.Errorf("proxy address %q must have the form host:port", address)
+	}
+	if host == "" {
+		return fmt.Errorf("proxy address %q has no host", address)
+	}
+	if port == "" {
+		return fmt.Errorf("proxy address %q has no port", address)
+	}
+
+	return ValidatePort(port)
+}
+
+// ValidateSendTimeout checks a send_timeout_seconds value: 0 disables the
+// timeout, anything else must be at least minSendTimeoutSeconds
+func ValidateSendTimeout(seconds int) error {
+	if seconds < 0 || seconds > 0 && seconds < minSendTimeoutSeconds {
+		return fmt.Errorf("timeout must be 0 or at least %d seconds", minSendTimeoutSeconds)
+	}
+	return nil
+}
+
+// ValidatePort checks that port is empty or a number between 1 and 65535
+func ValidatePort(port string) error {
+	port = strings.TrimSpace(port)
+	if port == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port %q: must be a number between 1 and 65535", port)
+	}
+
+	return nil
+}
+
+// DataDir returns the directory holding quickmail.json, where the outbox
+// and other local state is kept
+func DataDir(config *Config) (string, error) {
+	if config != nil && config.path != "" {
+		return filepath.Dir(config.path), nil
+	}
+	path, err := ExeConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Dir(path), nil
+}
+
+// defaultClipboardClearSeconds is used when the config does not set
+// clipboard_clear_seconds
+const defaultClipboardClearSeconds = 60
+
+// ClipboardClearDelay returns how long clipboard content is kept, where 0
+// means it is never cleared
+func (c *Config) ClipboardClearDelay() time.Duration {
+	if c == nil || c.ClipboardClearSeconds == nil {
+		return defaultClipboardClearSeconds * time.Second
+	}
+	return time.Duration(*c.ClipboardClearSeconds) * time.Second
+}
+
+// ShouldConfirmSend reports whether sending asks for confirmation first
+func (c *Config) ShouldConfirmSend() bool {
+	return c == nil || c.ConfirmSend == nil || *c.ConfirmSend
+}
+
+// ShouldConfirmClear reports whether Clear asks before discarding a
+// message
+func (c *Config) ShouldConfirmClear() bool {
+	return c == nil || c.ConfirmClear == nil || *c.ConfirmClear
+}
+
+// ShouldSaveDrafts reports whether the message being composed is autosaved
+// as a draft
+func (c *Config) ShouldSaveDrafts() bool {
+	return c == nil || c.Drafts == nil || *c.Drafts
+}
+
+// ShouldIsolateStreams reports whether every upload uses a fresh Tor
+// circuit
+func (c *Config) ShouldIsolateStreams() bool {
+	return c == nil || c.IsolateStreams == nil || *c.IsolateStreams
+}
+
+// ThemeMode returns the theme in lower case, or "" if none is configured
+func (c *Config) ThemeMode() string {
+	if c == nil {
+		return ""
+	}
+	return strings.ToLower(c.Theme)
+}
+
+// ProxyProtocol returns the proxy type in lower case, or ProxySOCKS5 if none
+// is configured
+func (c *Config) ProxyProtocol() string {
+	if c == nil || c.ProxyType == "" {
+		return ProxySOCKS5
+	}
+	return strings.ToLower(c.ProxyType)
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateServerAddress(t *testing.T) {
+	tests := []struct {
+		name, address, wantErr string
+	}{
+		{"v3 onion", "http://" + testOnionHost, ""},
+		{"bare v3 onion", testOnionHost, ""},
+		{"short onion of the sample config", "http://youronionaddress.onion", ""},
+		{"clearnet host", "https://mail.example.org", ""},
+		{"clearnet over http", "http://mail.example.org", ""},
+		{"port", "http://" + testOnionHost + ":8088", ""},
+		{"path", "https://example.org/quickmail/", ""},
+		{"port and path", "http://127.0.0.1:8088/qm", ""},
+		{"empty", "  ", "empty"},
+		{"other scheme", "ftp://example.org", "must start with http:// or https://"},
+		{"no host", "http://", "no host name"},
+		{"bad port", "http://example.org:99999", "invalid port"},
+		{"query", "https://example.org/?a=b", "query or fragment"},
+		{"fragment", "https://example.org/#top", "query or fragment"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateServerAddress(tt.address)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateServerAddress(%q) = %v, want nil", tt.address, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateServerAddress(%q) = %v, want %q", tt.address, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServerProfileURLs(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile ServerProfile
+		useTLS  bool
+		want    string
+	}{
+		{"onion with port field", ServerProfile{OnionAddress: "http://" + testOnionHost, Port: "8088"}, false, "http://" + testOnionHost + ":8088"},
+		{"bare onion stays http with TLS", ServerProfile{OnionAddress: testOnionHost, Port: "8088"}, true, "http://" + testOnionHost + ":8088"},
+		{"bare clearnet host with TLS", ServerProfile{OnionAddress: "example.org", Port: "443"}, true, "https://example.org:443"},
+		{"no port field", ServerProfile{OnionAddress: "https://example.org"}, true, "https://example.org"},
+		{"port in address wins", ServerProfile{OnionAddress: "http://example.org:9000", Port: "8088"}, false, "http://example.org:9000"},
+		{"path", ServerProfile{OnionAddress: "https://example.org/quickmail/", Port: "8443"}, false, "https://example.org:8443/quickmail"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.profile.BaseURL(tt.useTLS); got != tt.want {
+				t.Errorf("BaseURL = %q, want %q", got, tt.want)
+			}
+			if got := tt.profile.UploadURL(tt.useTLS); got != tt.want+"/upload" {
+				t.Errorf("UploadURL = %q, want %q", got, tt.want+"/upload")
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		retry  int
+		want   time.Duration
+	}{
+		{"first retry", RetryPolicy{BaseDelayMs: 2000}, 1, 2 * time.Second},
+		{"doubles", RetryPolicy{BaseDelayMs: 2000}, 3, 8 * time.Second},
+		{"capped", RetryPolicy{BaseDelayMs: 2000}, 20, maxRetryDelay},
+		{"no overflow", RetryPolicy{BaseDelayMs: 2000}, 100, maxRetryDelay},
+		{"huge retry", RetryPolicy{BaseDelayMs: 2000}, math.MaxInt, maxRetryDelay},
+		{"huge base", RetryPolicy{BaseDelayMs: math.MaxInt}, 1, maxRetryDelay},
+		{"no delay", RetryPolicy{}, math.MaxInt, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Delay(tt.retry); got != tt.want {
+				t.Errorf("Delay(%d) = %s, want %s", tt.retry, got, tt.want)
+			}
+		})
+	}
+
+	// Jitter is added, but never beyond the cap
+	policy := RetryPolicy{BaseDelayMs: 2000, JitterMs: math.MaxInt}
+	for retry := 1; retry < 50; retry++ {
+		if d := policy.Delay(retry); d < 0 || d > maxRetryDelay {
+			t.Fatalf("Delay(%d) with jitter = %s, want 0 to %s", retry, d, maxRetryDelay)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxHeaderLineLen is the longest header line RFC 2047 allows for lines
+// containing encoded-words
+const maxHeaderLineLen = 76
+
+// maxEncodedTextLen is the length of the encoded text in one encoded-word,
+// chosen so a complete "=?UTF-8?B?...?=" word fits on the first line after
+// "Subject: ". Continuation lines start with a single space and so are
+// shorter. In base64 this holds 39 bytes.
+const maxEncodedTextLen = maxHeaderLineLen - len("Subject: ") - len("=?UTF-8?B??=")
+
+// EncodeMIMESubject encodes the subject as RFC 2047 encoded-words with
+// mime.BEncoding (base64) or mime.QEncoding (quoted-printable), one per
+// line, folded with "\n " continuations, so no line of the Subject header
+// is longer than maxHeaderLineLen. The input is split on rune
+// boundaries so no UTF-8 sequence is broken across two words. Printable
+// ASCII subjects are returned unchanged.
+func EncodeMIMESubject(input string, encoding mime.WordEncoder) string {
+	if input == "" {
+		return ""
+	}
+	if !needsEncoding(input) {
+		return input
+	}
+
+	var words []string
+	chunkStart := 0
+	for i, r := range input {
+		end := i + utf8.RuneLen(r)
+		if i > chunkStart && encodedLen(input[chunkStart:end], encoding) > maxEncodedTextLen {
+			words = append(words, encodeWord(input[chunkStart:i], encoding))
+			chunkStart = i
+		}
+	}
+	words = append(words, encodeWord(input[chunkStart:], encoding))
+
+	return strings.Join(words, "\n ")
+}
+
+// encodeWord returns s as a single UTF-8 encoded-word
+func encodeWord(s string, encoding mime.WordEncoder) string {
+	if encoding == mime.QEncoding {
+		return "=?UTF-8?Q?" + qEncode(s) + "?="
+	}
+	return "=?UTF-8?B?" + base64.StdEncoding.EncodeToString([]byte(s)) + "?="
+}
+
+// encodedLen returns the length of s in the encoded text of a word
+func encodedLen(s string, encoding mime.WordEncoder) int {
+	if encoding == mime.QEncoding {
+		return len(qEncode(s))
+	}
+	return base64.StdEncoding.EncodedLen(len(s))
+}
+
+// qEncode returns s in the RFC 2047 Q encoding as mime.QEncoding writes
+// it: spaces become underscores, and "=", "?", "_" and anything besides
+// printable ASCII become =XX. mime.QEncoding itself cannot be used per
+// word, as it leaves ASCII-only chunks unencoded.
+func qEncode(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == ' ':
+			b.WriteByte('_')
+		case c > ' ' && c <= '~' && c != '=' && c != '?' && c != '_':
+			b.WriteByte(c)
+		default:
+			b.WriteByte('=')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0x0f])
+		}
+	}
+	return b.String()
+}
+
+// needsEncoding reports whether s contains anything besides printable ASCII
+func needsEncoding(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < ' ' || s[i] > '~') && s[i] != '\t' {
+			return true
+		}
+	}
+	return false
+}
+
+// isASCII reports whether s contains only ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// EncodeAddressList parses a comma-separated address list and MIME encodes
+// the display names, leaving the addresses themselves untouched
+func EncodeAddressList(input string) (string, error) {
+	addresses, err := mail.ParseAddressList(input)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		switch {
+		case address.Name == "":
+			encoded = append(encoded, address.Address)
+		case isASCII(address.Name):
+			encoded = append(encoded, address.String())
+		default:
+			name := mime.BEncoding.Encode("UTF-8", address.Name)
+			encoded = append(encoded, name+" <"+address.Address+">")
+		}
+	}
+
+	return strings.Join(encoded, ", "), nil
+}
+
+// StripBcc removes the Bcc header from the message and returns the Bcc
+// addresses separately, so they are routed without being visible to the
+// other recipients
+func StripBcc(message string) (string, []string, error) {
+	lines := strings.Split(message, "\n")
+
+	var kept []string
+	var bccValue strings.Builder
+	inBcc := false
+	inHeaders := true
+
+	for _, line := range lines {
+		if !inHeaders {
+			kept = append(kept, line)
+			continue
+		}
+		if strings.TrimRight(line, "\r") == "" {
+			inHeaders = false
+			kept = append(kept, line)
+			continue
+		}
+
+		isFolded := line[0] == ' ' || line[0] == '\t'
+		if isFolded && inBcc {
+			bccValue.WriteString(" " + strings.TrimSpace(line))
+			continue
+		}
+
+		inBcc = false
+		if !isFolded && strings.HasPrefix(strings.ToLower(line), "bcc:") {
+			inBcc = true
+			if bccValue.Len() > 0 {
+				bccValue.WriteString(",")
+			}
+			bccValue.WriteString(strings.TrimSpace(line[len("bcc:"):]))
+			continue
+		}
+		kept = append(kept, line)
+	}
+
+	stripped := strings.Join(kept, "\n")
+	if strings.TrimSpace(bccValue.String()) == "" {
+		return stripped, nil, nil
+	}
+
+	addresses, err := mail.ParseAddressList(bccValue.String())
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid Bcc: header: %w", err)
+	}
+
+	bcc := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		bcc = append(bcc, address.Address)
+	}
+	return stripped, bcc, nil
+}
+
+// SplitMessage splits a composed message at the first empty line into the
+// header block and the body. A message that does not start with a header
+// block is all body, with ok false, so plain text is never mistaken for
+// headers. A message of only headers has an empty body.
+func SplitMessage(message string) (headers, body string, ok bool) {
+	message = strings.ReplaceAll(message, "\r\n", "\n")
+	headers, body, found := strings.Cut(message, "\n\n")
+	if !found {
+		headers, body = strings.TrimRight(message, "\n"), ""
+	}
+	if !isHeaderBlock(headers) {
+		return "", message, false
+	}
+	return headers, body, true
+}
+
+// JoinMessage puts a message split by SplitMessage back together
+func JoinMessage(headers, body string) string {
+	if headers == "" {
+		return body
+	}
+	return headers + "\n\n" + body
+}
+
+// isHeaderBlock reports whether every line of block is an RFC 5322 header
+// field, a name of printable ASCII, a colon and a value, or the folded
+// continuation of the field before it
+func isHeaderBlock(block string) bool {
+	for i, line := range strings.Split(block, "\n") {
+		if line != "" && (line[0] == ' ' || line[0] == '\t') {
+			if i == 0 {
+				return false
+			}
+			continue
+		}
+
+		name, _, ok := strings.Cut(line, ":")
+		if !ok || name == "" {
+			return false
+		}
+		for _, c := range []byte(name) {
+			if c < '!' || c > '~' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// MessageSubject returns the decoded Subject header of a message, or ""
+// if it has none
+func MessageSubject(message string) string {
+	headers, _, _ := SplitMessage(message)
+	msg, err := mail.ReadMessage(strings.NewReader(headers + "\n\n"))
+	if err != nil {
+		return ""
+	}
+
+	subject := msg.Header.Get("Subject")
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(subject); err == nil {
+		subject = decoded
+	}
+	return subject
+}
+
+// ExtractMIMEHeaders removes MIME-Version, Content-Type and
+// Content-Transfer-Encoding from a header block, returning the remaining
+// headers and the removed Content-Type and Content-Transfer-Encoding values
+func ExtractMIMEHeaders(headers string) (rest, contentType, encoding string) {
+	var kept []string
+	var current *string
+	var discard bool
+
+	for _, line := range strings.Split(headers, "\n") {
+		if line != "" && (line[0] == ' ' || line[0] == '\t') {
+			if current != nil {
+				*current += " " + strings.TrimSpace(line)
+				continue
+			}
+			if discard {
+				continue
+			}
+			kept = append(kept, line)
+			continue
+		}
+
+		current = nil
+		discard = false
+		lower := strings.ToLower(line)
+		switch {
+		case strings.HasPrefix(lower, "content-type:"):
+			contentType = strings.TrimSpace(line[len("content-type:"):])
+			current = &contentType
+		case strings.HasPrefix(lower, "content-transfer-encoding:"):
+			encoding = strings.TrimSpace(line[len("content-transfer-encoding:"):])
+			current = &encoding
+		case strings.HasPrefix(lower, "mime-version:"):
+			discard = true
+		default:
+			kept = append(kept, line)
+		}
+	}
+
+	return strings.Join(kept, "\n"), contentType, encoding
+}
+
+// UploadContentType returns the Content-Type of the upload request: the
+// multipart type and boundary of a message with attachments, otherwise
+// application/octet-stream for the raw message text
+func UploadContentType(message []byte) string {
+	end := bytes.Index(message, []byte("\n\n"))
+	if crlfEnd := bytes.Index(message, []byte("\r\n\r\n")); crlfEnd >= 0 && (end < 0 || crlfEnd < end) {
+		end = crlfEnd
+	}
+	if end < 0 {
+		return "application/octet-stream"
+	}
+
+	headers := strings.ReplaceAll(string(message[:end]), "\r\n", "\n")
+	_, contentType, _ := ExtractMIMEHeaders(headers)
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return "application/octet-stream"
+	}
+	return contentType
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"mime"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEncodeMIMESubject(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		encoding mime.WordEncoder
+		want     string
+	}{
+		{"empty", "", mime.BEncoding, ""},
+		{"ascii unchanged", "Meeting at 10:00", mime.BEncoding, "Meeting at 10:00"},
+		{"ascii unchanged in Q", "Re: =?not encoded?=", mime.QEncoding, "Re: =?not encoded?="},
+		{"umlaut base64", "Grüße", mime.BEncoding, "=?UTF-8?B?R3LDvMOfZQ==?="},
+		{"emoji", "🔒", mime.BEncoding, "=?UTF-8?B?8J+Ukg==?="},
+		{"umlaut Q", "Grüße aus Köln", mime.QEncoding, "=?UTF-8?Q?Gr=C3=BC=C3=9Fe_aus_K=C3=B6ln?="},
+		{"Q escapes specials", "a_b=c? ü", mime.QEncoding, "=?UTF-8?Q?a=5Fb=3Dc=3F_=C3=BC?="},
+		{"control character", "tab\tand\x7f", mime.BEncoding, "=?UTF-8?B?dGFiCWFuZH8=?="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EncodeMIMESubject(tt.input, tt.encoding); got != tt.want {
+				t.Errorf("EncodeMIMESubject(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeMIMESubjectRoundTrip(t *testing.T) {
+	subjects := []string{
+		"Grüße",
+		strings.Repeat("Größenänderung der Überweisungsbeträge ", 5),
+		strings.Repeat("🔒📧", 30),
+		strings.Repeat("日本語の件名", 20),
+		"mixed ASCII and ü " + strings.Repeat("x", 100) + " and €",
+	}
+
+	decoder := new(mime.WordDecoder)
+	for _, encoding := range []mime.WordEncoder{mime.BEncoding, mime.QEncoding} {
+		for _, subject := range subjects {
+			encoded := EncodeMIMESubject(subject, encoding)
+
+			for _, word := range strings.Split(encoded, "\n ") {
+				// Every word must decode on its own, which fails if a
+				// UTF-8 sequence was split across two words
+				decoded, err := decoder.Decode(word)
+				if err != nil {
+					t.Fatalf("%q: word %q does not decode: %v", subject, word, err)
+				}
+				if !utf8.ValidString(decoded) {
+					t.Errorf("%q: word %q splits a UTF-8 sequence", subject, word)
+				}
+			}
+
+			decoded, err := decoder.DecodeHeader(encoded)
+			if err != nil {
+				t.Fatalf("%q: DecodeHeader: %v", subject, err)
+			}
+			if decoded != subject {
+				t.Errorf("round trip of %q gave %q", subject, decoded)
+			}
+		}
+	}
+}
+
+func TestEncodeMIMESubjectLineLength(t *testing.T) {
+	subjects := []string{
+		"Grüße",
+		strings.Repeat("ü", 200),
+		strings.Repeat("Größenänderung ", 20),
+		strings.Repeat("🔒", 100),
+		// Characters that Q-encode to three times their length
+		strings.Repeat("=?_", 60) + "ü",
+		"ü" + strings.Repeat("a", 300),
+	}
+
+	for _, encoding := range []mime.WordEncoder{mime.BEncoding, mime.QEncoding} {
+		for _, subject := range subjects {
+			header := "Subject: " + EncodeMIMESubject(subject, encoding)
+			for i, line := range strings.Split(header, "\n") {
+				if len(line) > maxHeaderLineLen {
+					t.Errorf("%q encoded with %q: line %d has %d characters, more than %d:\n%s",
+						subject, encoding, i+1, len(line), maxHeaderLineLen, line)
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("%q encoded with %q: continuation line %d does not start with a space", subject, encoding, i+1)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// socks4aDialer connects through a SOCKS4a proxy, which golang.org/x/net/proxy
+// does not support. The proxy resolves host names, so onion addresses work
+// as with SOCKS5. SOCKS4a has no passwords; a user name is sent as the
+// user ID.
+type socks4aDialer struct {
+	network string
+	address string
+	userID  string
+}
+
+// socks4aTimeout bounds the connect and handshake with the proxy when the
+// context has no deadline
+const socks4aTimeout = 30 * time.Second
+
+// Dial connects to addr through the proxy
+func (d *socks4aDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+// DialContext connects to addr through the proxy, giving up when ctx is
+// done
+func (d *socks4aDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if network != "tcp" && network != "tcp4" && network != "tcp6" {
+		return nil, fmt.Errorf("SOCKS4a does not support network %s", network)
+	}
+
+	host, portText, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portText, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", portText)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, d.network, d.address)
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(socks4aTimeout)
+	}
+	conn.SetDeadline(deadline)
+	// Cancelling ctx interrupts the handshake
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	// Version 4, CONNECT, port, the invalid IP 0.0.0.1 that tells the proxy
+	// to resolve the host name sent after the user ID
+	request := []byte{0x04, 0x01, byte(port >> 8), byte(port), 0, 0, 0, 1}
+	request = append(request, d.userID...)
+	request = append(request, 0)
+	request = append(request, host...)
+	request = append(request, 0)
+	if _, err := conn.Write(request); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reply := make([]byte, 8)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	if reply[0] != 0x00 {
+		conn.Close()
+		return nil, errors.New("not a SOCKS4a proxy")
+	}
+	if reply[1] != 0x5a {
+		conn.Close()
+		return nil, fmt.Errorf("proxy rejected the connection to %s (0x%02x)", addr, reply[1])
+	}
+
+	if !stop() {
+		conn.Close()
+		return nil, ctx.Err()
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
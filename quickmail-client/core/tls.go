@@ -0,0 +1,31 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// NewTLSConfig returns the TLS settings for clearnet servers. With caPath
+// only the CA certificates in that PEM file are trusted, so a server with
+// a self-signed certificate can be used; without it the system roots are.
+func NewTLSConfig(caPath string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caPath == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(caPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read TLS CA certificate: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("no PEM certificate found in the TLS CA certificate file")
+	}
+	config.RootCAs = pool
+	return config, nil
+}
@@ -0,0 +1,242 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// ProxyError is returned when the Tor SOCKS proxy cannot be reached
+type ProxyError struct {
+	Address string
+	Err     error
+}
+
+func (e *ProxyError) Error() string {
+	return fmt.Sprintf("can't connect to Tor proxy at %s: %v", e.Address, e.Err)
+}
+
+func (e *ProxyError) Unwrap() error {
+	return e.Err
+}
+
+// unixProxyPrefix marks a proxy address as a Unix domain socket path, as
+// used by Tor's "SocksPort unix:/run/tor/socks"
+const unixProxyPrefix = "unix:"
+
+// proxyNetwork splits a proxy address into the network and address to dial
+func proxyNetwork(address string) (network, addr string) {
+	if path, ok := strings.CutPrefix(address, unixProxyPrefix); ok {
+		return "unix", path
+	}
+	return "tcp", address
+}
+
+// fallbackTorProxies are probed when the configured proxy does not answer:
+// the system Tor daemon and Tor Browser
+var fallbackTorProxies = []string{"127.0.0.1:9050", "127.0.0.1:9150"}
+
+// probeTimeout bounds the connect and handshake of a single proxy probe
+const probeTimeout = 5 * time.Second
+
+// probeSOCKS5 connects to address and performs the SOCKS5 method
+// negotiation, offering "no authentication" and, if withAuth is set,
+// username/password authentication
+func probeSOCKS5(address string, withAuth bool) error {
+	network, addr := proxyNetwork(address)
+	conn, err := net.DialTimeout(network, addr, probeTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(probeTimeout))
+
+	greeting := []byte{0x05, 0x01, 0x00}
+	if withAuth {
+		greeting = []byte{0x05, 0x02, 0x00, 0x02}
+	}
+	if _, err := conn.Write(greeting); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("no SOCKS5 reply from %s: %w", address, err)
+	}
+	if reply[0] != 0x05 {
+		return fmt.Errorf("%s is not a SOCKS5 proxy", address)
+	}
+	if reply[1] != 0x00 && !(withAuth && reply[1] == 0x02) {
+		return fmt.Errorf("%s rejected the authentication method (0x%02x)", address, reply[1])
+	}
+
+	return nil
+}
+
+// probeSOCKS4a connects to address. A SOCKS4a proxy answers only to a
+// connect request, so accepting the connection is all that is checked.
+func probeSOCKS4a(address string) error {
+	network, addr := proxyNetwork(address)
+	conn, err := net.DialTimeout(network, addr, probeTimeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// DetectTorProxy returns the first of the configured proxy and the
+// fallback proxies that answers a handshake in protocol, ProxySOCKS5 or
+// ProxySOCKS4a. If none answers, the ProxyError holds the error of every
+// candidate.
+func DetectTorProxy(configured, protocol string, withAuth bool) (string, error) {
+	var candidates []string
+	if configured != "" {
+		candidates = append(candidates, configured)
+	}
+	for _, fallback := range fallbackTorProxies {
+		if fallback != configured {
+			candidates = append(candidates, fallback)
+		}
+	}
+
+	var errs []error
+	for _, candidate := range candidates {
+		var err error
+		if protocol == ProxySOCKS4a {
+			err = probeSOCKS4a(candidate)
+		} else {
+			err = probeSOCKS5(candidate, withAuth)
+		}
+		if err == nil {
+			return candidate, nil
+		}
+		errs = append(errs, err)
+	}
+
+	return "", &ProxyError{Address: strings.Join(candidates, ", "), Err: errors.Join(errs...)}
+}
+
+// NewIsolationAuth returns random proxy credentials for a single upload.
+// Tor keeps streams with different SOCKS credentials on different
+// circuits (IsolateSOCKSAuth, on by default), so uploads made with
+// different credentials cannot be linked by their circuit. SOCKS4a has no
+// password, but Tor isolates by the user ID alone.
+func NewIsolationAuth() (*proxy.Auth, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("could not create stream isolation credentials: %w", err)
+	}
+	return &proxy.Auth{
+		User:     hex.EncodeToString(random[:16]),
+		Password: hex.EncodeToString(random[16:]),
+	}, nil
+}
+
+// NewTorTransport returns an HTTP transport that connects through the
+// proxy at torProxy in protocol, authenticating with auth if it is not
+// nil. With ProxyDirect torProxy is ignored and connections bypass Tor.
+// Connecting, SOCKS handshake included, fails with a TimeoutError after
+// connectTimeout and stops when the context of the request is done.
+func NewTorTransport(torProxy, protocol string, auth *proxy.Auth, connectTimeout time.Duration) (*http.Transport, error) {
+	network, address := proxyNetwork(torProxy)
+
+	var dialer proxy.ContextDialer
+	switch protocol {
+	case ProxyDirect:
+		dialer = &net.Dialer{}
+	case ProxySOCKS4a:
+		d := &socks4aDialer{network: network, address: address}
+		if auth != nil {
+			d.userID = auth.User
+		}
+		dialer = d
+	default:
+		socks, err := proxy.SOCKS5(network, address, auth, proxy.Direct)
+		if err != nil {
+			return nil, &ProxyError{Address: torProxy, Err: err}
+		}
+		dialer = socks.(proxy.ContextDialer)
+	}
+
+	return &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+			defer cancel()
+			conn, err := dialer.DialContext(dialCtx, network, addr)
+			if err != nil && ctx.Err() == nil && dialCtx.Err() != nil {
+				return nil, &TimeoutError{After: connectTimeout, Connect: true}
+			}
+			return conn, err
+		},
+	}, nil
+}
+
+// NewTorClient returns an HTTP client that connects through the proxy at
+// torProxy with the proxy type and send timeout of config. With use_tls, HTTPS
+// connections trust the configured CA certificate. A nil config uses the
+// defaults.
+func NewTorClient(torProxy string, auth *proxy.Auth, config *Config) (*http.Client, error) {
+	timeout := DefaultTimeout
+	if config != nil {
+		timeout = config.Timeout()
+	}
+
+	transport, err := NewTorTransport(torProxy, config.ProxyProtocol(), auth, config.ConnectTimeout())
+	if err != nil {
+		return nil, err
+	}
+	if config != nil && config.UseTLS {
+		transport.TLSClientConfig, err = NewTLSConfig(config.TLSCACert)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}, nil
+}
+
+// TorCheckURL answers whether a request arrived through Tor
+const TorCheckURL = "https://check.torproject.org/api/ip"
+
+// CheckTorCircuit fetches TorCheckURL through torProxy in protocol and
+// confirms that the request left through the Tor network
+func CheckTorCircuit(torProxy, protocol string, auth *proxy.Auth) error {
+	transport, err := NewTorTransport(torProxy, protocol, auth, DefaultConnectTimeout)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   DefaultTimeout,
+	}
+
+	response, err := client.Get(TorCheckURL)
+	if err != nil {
+		return fmt.Errorf("could not reach %s: %w", TorCheckURL, err)
+	}
+	defer response.Body.Close()
+
+	var result struct {
+		IsTor bool
+	}
+	if err := json.NewDecoder(io.LimitReader(response.Body, 4096)).Decode(&result); err != nil {
+		return fmt.Errorf("unexpected answer from %s: %w", TorCheckURL, err)
+	}
+	if !result.IsTor {
+		return errors.New("the proxy does not route through Tor")
+	}
+	return nil
+}
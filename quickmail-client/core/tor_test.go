@@ -0,0 +1,318 @@
+package core
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+func TestNewIsolationAuth(t *testing.T) {
+	const n = 100
+	seen := make(map[string]bool)
+
+	for i := 0; i < n; i++ {
+		auth, err := NewIsolationAuth()
+		if err != nil {
+			t.Fatalf("NewIsolationAuth: %v", err)
+		}
+
+		for _, field := range []struct{ name, value string }{{"User", auth.User}, {"Password", auth.Password}} {
+			if len(field.value) != 32 {
+				t.Errorf("%s %q has %d characters, want 32", field.name, field.value, len(field.value))
+			}
+			if _, err := hex.DecodeString(field.value); err != nil {
+				t.Errorf("%s %q is not hex: %v", field.name, field.value, err)
+			}
+			if seen[field.value] {
+				t.Fatalf("%s %q was handed out before", field.name, field.value)
+			}
+			seen[field.value] = true
+		}
+	}
+}
+
+func TestDetectTorProxyErrors(t *testing.T) {
+	// A port nothing listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := listener.Addr().String()
+	listener.Close()
+
+	// A server that answers, but not as a SOCKS5 proxy
+	other, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	go func() {
+		for {
+			conn, err := other.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("HTTP/1.0 400 Bad Request\r\n\r\n"))
+			conn.Close()
+		}
+	}()
+	notSOCKS := other.Addr().String()
+
+	saved := fallbackTorProxies
+	fallbackTorProxies = []string{notSOCKS}
+	t.Cleanup(func() { fallbackTorProxies = saved })
+
+	_, err = DetectTorProxy(closed, ProxySOCKS5, false)
+
+	var proxyErr *ProxyError
+	if !errors.As(err, &proxyErr) {
+		t.Fatalf("DetectTorProxy error = %v, want a ProxyError", err)
+	}
+	if want := closed + ", " + notSOCKS; proxyErr.Address != want {
+		t.Errorf("Address = %q, want %q", proxyErr.Address, want)
+	}
+	// Every candidate's failure is kept, not only the last one
+	for _, candidate := range []string{closed, notSOCKS} {
+		if !strings.Contains(err.Error(), candidate) {
+			t.Errorf("error %q does not mention %s", err, candidate)
+		}
+	}
+	if !strings.Contains(err.Error(), "not a SOCKS5 proxy") {
+		t.Errorf("error %q does not say why %s failed", err, notSOCKS)
+	}
+}
+
+// fakeSOCKSProxy is a minimal SOCKS5 and SOCKS4a proxy that records the
+// credentials and target of every connection and connects it to target
+type fakeSOCKSProxy struct {
+	listener net.Listener
+	target   string
+
+	mu    sync.Mutex
+	conns []socksConn
+}
+
+// socksConn is what the proxy learned about one connection
+type socksConn struct {
+	user, password, host string
+}
+
+func newFakeSOCKSProxy(t *testing.T, target string) *fakeSOCKSProxy {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeSOCKSProxy{listener: listener, target: target}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(conn)
+		}
+	}()
+	return p
+}
+
+func (p *fakeSOCKSProxy) connections() []socksConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]socksConn(nil), p.conns...)
+}
+
+func (p *fakeSOCKSProxy) serve(client net.Conn) {
+	defer client.Close()
+	r := bufio.NewReader(client)
+
+	version, err := r.ReadByte()
+	if err != nil {
+		return
+	}
+	var c socksConn
+	var reply []byte
+	switch version {
+	case 5:
+		c, err = handshakeSOCKS5(r, client)
+		reply = []byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	case 4:
+		c, err = handshakeSOCKS4a(r)
+		reply = []byte{0, 0x5a, 0, 0, 0, 0, 0, 0}
+	default:
+		err = errors.New("unknown SOCKS version")
+	}
+	if err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	p.conns = append(p.conns, c)
+	p.mu.Unlock()
+
+	server, err := net.Dial("tcp", p.target)
+	if err != nil {
+		return
+	}
+	defer server.Close()
+	if _, err := client.Write(reply); err != nil {
+		return
+	}
+
+	go io.Copy(server, r)
+	io.Copy(client, server)
+}
+
+// handshakeSOCKS5 answers the method selection, the username/password
+// authentication of RFC 1929 if offered, and reads a CONNECT to a domain
+func handshakeSOCKS5(r *bufio.Reader, w io.Writer) (socksConn, error) {
+	var c socksConn
+	n, err := r.ReadByte()
+	if err != nil {
+		return c, err
+	}
+	methods := make([]byte, n)
+	if _, err := io.ReadFull(r, methods); err != nil {
+		return c, err
+	}
+
+	method := byte(0)
+	for _, m := range methods {
+		if m == 2 {
+			method = 2
+		}
+	}
+	if _, err := w.Write([]byte{5, method}); err != nil {
+		return c, err
+	}
+	if method == 2 {
+		if _, err := r.ReadByte(); err != nil {
+			return c, err
+		}
+		if c.user, err = readSOCKS5String(r); err != nil {
+			return c, err
+		}
+		if c.password, err = readSOCKS5String(r); err != nil {
+			return c, err
+		}
+		if _, err := w.Write([]byte{1, 0}); err != nil {
+			return c, err
+		}
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return c, err
+	}
+	if header[1] != 1 || header[3] != 3 {
+		return c, errors.New("not a CONNECT to a domain name")
+	}
+	host, err := readSOCKS5String(r)
+	if err != nil {
+		return c, err
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(r, port); err != nil {
+		return c, err
+	}
+	c.host = net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+	return c, nil
+}
+
+func readSOCKS5String(r *bufio.Reader) (string, error) {
+	n, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(r, b)
+	return string(b), err
+}
+
+// handshakeSOCKS4a reads a SOCKS4a CONNECT request
+func handshakeSOCKS4a(r *bufio.Reader) (socksConn, error) {
+	var c socksConn
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return c, err
+	}
+	user, err := r.ReadString(0)
+	if err != nil {
+		return c, err
+	}
+	host, err := r.ReadString(0)
+	if err != nil {
+		return c, err
+	}
+	c.user = user[:len(user)-1]
+	c.host = net.JoinHostPort(host[:len(host)-1], strconv.Itoa(int(binary.BigEndian.Uint16(header[1:3]))))
+	return c, nil
+}
+
+func TestIsolationAuthReachesProxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	socks := newFakeSOCKSProxy(t, server.Listener.Addr().String())
+
+	const target = "http://" + testOnionHost + ":8088/upload"
+
+	for _, protocol := range []string{ProxySOCKS5, ProxySOCKS4a} {
+		t.Run(protocol, func(t *testing.T) {
+			before := len(socks.connections())
+
+			// Two messages, each with credentials of its own as
+			// sendClient hands them out with isolate_streams
+			var sent []*proxy.Auth
+			for i := 0; i < 2; i++ {
+				auth, err := NewIsolationAuth()
+				if err != nil {
+					t.Fatal(err)
+				}
+				transport, err := NewTorTransport(socks.listener.Addr().String(), protocol, auth, 5*time.Second)
+				if err != nil {
+					t.Fatal(err)
+				}
+				client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+				if err := Upload(t.Context(), client, target, []byte("message"), UploadOptions{}); err != nil {
+					t.Fatalf("Upload: %v", err)
+				}
+				transport.CloseIdleConnections()
+				sent = append(sent, auth)
+			}
+
+			conns := socks.connections()[before:]
+			if len(conns) != len(sent) {
+				t.Fatalf("proxy saw %d connections, want %d", len(conns), len(sent))
+			}
+			for i, c := range conns {
+				if c.host != testOnionHost+":8088" {
+					t.Errorf("connection %d went to %q, want the unresolved onion host", i, c.host)
+				}
+				if c.user != sent[i].User {
+					t.Errorf("connection %d authenticated as %q, want %q", i, c.user, sent[i].User)
+				}
+				// SOCKS4a has no password; Tor isolates by the user ID
+				if protocol == ProxySOCKS5 && c.password != sent[i].Password {
+					t.Errorf("connection %d used password %q, want %q", i, c.password, sent[i].Password)
+				}
+			}
+			if conns[0].user == conns[1].user {
+				t.Error("both uploads used the same credentials and would share a circuit")
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetryError is returned by Upload once all attempts have failed or
+// a failure is not worth retrying
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	if e.Attempts == 1 {
+		return fmt.Sprintf("failed after 1 attempt: %v", e.Err)
+	}
+	return fmt.Sprintf("failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is returned when the server answers with a status other than
+// 200 OK
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status: %s, body: %s", e.Status, e.Body)
+}
+
+// TimeoutError is returned when an upload attempt runs out of time, either
+// while connecting or while waiting for the server
+type TimeoutError struct {
+	After   time.Duration
+	Connect bool
+}
+
+func (e *TimeoutError) Error() string {
+	seconds := int(e.After / time.Second)
+	if e.Connect {
+		return fmt.Sprintf("could not connect to the server within %ds", seconds)
+	}
+	return fmt.Sprintf("server did not respond within %ds", seconds)
+}
+
+// IsTransient reports whether an upload error may go away on retry. Network
+// errors and timeouts are retried, as are the gateway and unavailable
+// statuses a busy onion service answers with. Any other HTTP error is an
+// explicit answer from the server and stops retrying.
+func IsTransient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// UploadOptions controls a single call to Upload
+type UploadOptions struct {
+	// Bcc addresses are passed to the server in the BccHeader request header
+	Bcc []string
+
+	// Policy sets the number of attempts and the backoff between them
+	Policy RetryPolicy
+
+	// Status, if set, receives a short description of each attempt
+	Status func(string)
+
+	// Progress, if set, receives the number of bytes sent so far
+	Progress func(sent, total int64)
+}
+
+// Upload posts data to serverURL using client, retrying failed attempts
+// with exponential backoff according to opts.Policy. data is not read
+// after Upload returns.
+func Upload(ctx context.Context, client *http.Client, serverURL string, data []byte, opts UploadOptions) error {
+	status := opts.Status
+	if status == nil {
+		status = func(string) {}
+	}
+	policy := opts.Policy
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt == 1 {
+			status("Sending...")
+		} else {
+			status(fmt.Sprintf("Retry %d of %d...", attempt-1, policy.MaxAttempts-1))
+		}
+
+		err := uploadOnce(ctx, client, serverURL, data, opts)
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= policy.MaxAttempts || !IsTransient(err) {
+			status(fmt.Sprintf("Send failed after %d of %d attempts", attempt, policy.MaxAttempts))
+			return &RetryError{Attempts: attempt, Err: err}
+		}
+
+		delay := policy.Delay(attempt)
+		status(fmt.Sprintf("Attempt %d of %d failed, retrying in %s...",
+			attempt, policy.MaxAttempts, delay.Round(time.Second)))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// errBodyClosed is returned when the transport reads the request body
+// after the attempt has returned
+var errBodyClosed = errors.New("request body closed")
+
+// progressReader counts the bytes read from r and reports the running
+// total on the progress channel, if there is one. Once closed it no
+// longer touches r, so the caller may wipe the data after Upload returns.
+type progressReader struct {
+	r        io.Reader
+	sent     int64
+	progress chan int64
+	closed   bool
+	mu       sync.Mutex
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return 0, errBodyClosed
+	}
+
+	n, err := p.r.Read(b)
+	p.sent += int64(n)
+	if n > 0 && p.progress != nil {
+		p.progress <- p.sent
+	}
+	return n, err
+}
+
+// close stops reading and progress reporting; the transport may still read
+// the body after the request returned, so Read checks closed first
+func (p *progressReader) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	if p.progress != nil {
+		close(p.progress)
+	}
+}
+
+// uploadOnce performs a single POST of data to serverURL
+func uploadOnce(ctx context.Context, client *http.Client, serverURL string, data []byte, opts UploadOptions) error {
+	body := &progressReader{r: bytes.NewReader(data)}
+	done := make(chan struct{})
+	if opts.Progress != nil {
+		total := int64(len(data))
+		opts.Progress(0, total)
+
+		body.progress = make(chan int64, 16)
+		go func() {
+			defer close(done)
+			for sent := range body.progress {
+				opts.Progress(sent, total)
+			}
+		}()
+	} else {
+		close(done)
+	}
+	defer func() {
+		body.close()
+		<-done
+	}()
+
+	request, err := http.NewRequestWithContext(ctx, "POST", serverURL, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	request.ContentLength = int64(len(data))
+
+	request.Header.Set("Content-Type", UploadContentType(data))
+	if len(opts.Bcc) > 0 {
+		request.Header.Set(BccHeader, strings.Join(opts.Bcc, ", "))
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var timeoutErr *TimeoutError
+		if errors.As(err, &timeoutErr) {
+			return timeoutErr
+		}
+		var netErr net.Error
+		if client.Timeout > 0 && errors.As(err, &netErr) && netErr.Timeout() {
+			return &TimeoutError{After: client.Timeout}
+		}
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer response.Body.Close()
+
+	responseBody, _ := io.ReadAll(response.Body)
+
+	if response.StatusCode != http.StatusOK {
+		return &StatusError{
+			StatusCode: response.StatusCode,
+			Status:     response.Status,
+			Body:       string(responseBody),
+		}
+	}
+
+	return nil
+}
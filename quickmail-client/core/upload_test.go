@@ -0,0 +1,288 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// testOnionHost stands in for the server's onion address, which only the
+// proxy can resolve
+const testOnionHost = "yourserversfiftysixcharacterversionthreeonionaddressgoes.onion"
+
+// fakeSOCKSDialer plays the Tor proxy: it records the addresses it is asked
+// to reach and connects every one of them to the test server
+type fakeSOCKSDialer struct {
+	server string
+
+	mu    sync.Mutex
+	dials []string
+}
+
+var _ proxy.Dialer = (*fakeSOCKSDialer)(nil)
+
+func (d *fakeSOCKSDialer) Dial(network, addr string) (net.Conn, error) {
+	d.mu.Lock()
+	d.dials = append(d.dials, addr)
+	d.mu.Unlock()
+	return net.Dial(network, d.server)
+}
+
+func (d *fakeSOCKSDialer) dialed() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.dials...)
+}
+
+// newTestUpload starts a server answering with handler and returns a
+// client reaching it through a fake SOCKS dialer, the onion URL to upload
+// to and the dialer
+func newTestUpload(t *testing.T, handler http.HandlerFunc) (*http.Client, string, *fakeSOCKSDialer) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	dialer := &fakeSOCKSDialer{server: server.Listener.Addr().String()}
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialer.Dial(network, addr)
+			},
+		},
+	}
+	t.Cleanup(client.CloseIdleConnections)
+	return client, "http://" + testOnionHost + ":8088/upload", dialer
+}
+
+// stall blocks a handler until the client goes away or the test ends;
+// the server only notices a closed connection once the body is read
+func stall(r *http.Request, release <-chan struct{}) {
+	io.Copy(io.Discard, r.Body)
+	select {
+	case <-r.Context().Done():
+	case <-release:
+	}
+}
+
+// fastRetries retries without waiting, so the tests do not sleep
+func fastRetries(attempts int) RetryPolicy {
+	return RetryPolicy{MaxAttempts: attempts}
+}
+
+func TestUploadSuccess(t *testing.T) {
+	message := "To: alice@example.org\nSubject: Test\n\nHello, World!\n"
+
+	var got struct {
+		method, contentType, bcc, body string
+	}
+	client, serverURL, dialer := newTestUpload(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got.method = r.Method
+		got.contentType = r.Header.Get("Content-Type")
+		got.bcc = r.Header.Get(BccHeader)
+		got.body = string(body)
+	})
+
+	var lastSent, lastTotal int64
+	err := Upload(context.Background(), client, serverURL, []byte(message), UploadOptions{
+		Bcc:    []string{"bob@example.org", "carol@example.org"},
+		Policy: fastRetries(3),
+		Progress: func(sent, total int64) {
+			lastSent, lastTotal = sent, total
+		},
+	})
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	if got.method != "POST" {
+		t.Errorf("method = %q, want POST", got.method)
+	}
+	if got.contentType != "application/octet-stream" {
+		t.Errorf("Content-Type = %q, want application/octet-stream", got.contentType)
+	}
+	if got.bcc != "bob@example.org, carol@example.org" {
+		t.Errorf("%s = %q", BccHeader, got.bcc)
+	}
+	if got.body != message {
+		t.Errorf("body = %q, want %q", got.body, message)
+	}
+	if lastSent != int64(len(message)) || lastTotal != int64(len(message)) {
+		t.Errorf("last progress = %d of %d, want %d of %d", lastSent, lastTotal, len(message), len(message))
+	}
+
+	// The host name must reach the proxy unresolved
+	if dials := dialer.dialed(); len(dials) != 1 || dials[0] != testOnionHost+":8088" {
+		t.Errorf("proxy dialed %q, want [%s:8088]", dials, testOnionHost)
+	}
+}
+
+func TestUploadStatusError(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		attempts int
+	}{
+		// The server's answer is final, so it is not retried
+		{"forbidden", http.StatusForbidden, 1},
+		{"too large", http.StatusRequestEntityTooLarge, 1},
+		{"server error", http.StatusInternalServerError, 1},
+		// A busy onion service may answer on the next attempt
+		{"bad gateway", http.StatusBadGateway, 3},
+		{"unavailable", http.StatusServiceUnavailable, 3},
+		{"gateway timeout", http.StatusGatewayTimeout, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			client, serverURL, _ := newTestUpload(t, func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				http.Error(w, "refused", tt.status)
+			})
+
+			err := Upload(context.Background(), client, serverURL, []byte("message"), UploadOptions{Policy: fastRetries(3)})
+
+			var retryErr *RetryError
+			if !errors.As(err, &retryErr) {
+				t.Fatalf("Upload error = %v, want a RetryError", err)
+			}
+			if retryErr.Attempts != tt.attempts {
+				t.Errorf("Attempts = %d, want %d", retryErr.Attempts, tt.attempts)
+			}
+			if n := int(requests.Load()); n != tt.attempts {
+				t.Errorf("server got %d requests, want %d", n, tt.attempts)
+			}
+
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) {
+				t.Fatalf("Upload error = %v, want a StatusError", err)
+			}
+			if statusErr.StatusCode != tt.status {
+				t.Errorf("StatusCode = %d, want %d", statusErr.StatusCode, tt.status)
+			}
+			if !strings.Contains(statusErr.Body, "refused") {
+				t.Errorf("Body = %q, want the server's answer", statusErr.Body)
+			}
+		})
+	}
+}
+
+func TestUploadRetry(t *testing.T) {
+	var requests atomic.Int32
+	client, serverURL, dialer := newTestUpload(t, func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first two attempts like a service that is still starting
+		if requests.Add(1) <= 2 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		}
+	})
+
+	var statuses []string
+	err := Upload(context.Background(), client, serverURL, []byte("message"), UploadOptions{
+		Policy: fastRetries(4),
+		Status: func(status string) {
+			statuses = append(statuses, status)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("server got %d requests, want 3", n)
+	}
+	if len(dialer.dialed()) == 0 {
+		t.Error("the proxy was not used")
+	}
+
+	want := []string{"Sending...", "Retry 1 of 3...", "Retry 2 of 3..."}
+	for _, status := range want {
+		found := false
+		for _, s := range statuses {
+			found = found || s == status
+		}
+		if !found {
+			t.Errorf("statuses %q lack %q", statuses, status)
+		}
+	}
+}
+
+func TestUploadTimeout(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	client, serverURL, _ := newTestUpload(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		// Never answer, like a server behind a stalled circuit
+		stall(r, release)
+	})
+	t.Cleanup(func() { close(release) })
+	client.Timeout = 50 * time.Millisecond
+
+	err := Upload(context.Background(), client, serverURL, []byte("message"), UploadOptions{Policy: fastRetries(2)})
+
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("Upload error = %v, want a TimeoutError", err)
+	}
+	if timeoutErr.Connect || timeoutErr.After != client.Timeout {
+		t.Errorf("TimeoutError = %+v, want a response timeout after %s", timeoutErr, client.Timeout)
+	}
+	// Timeouts are transient, so the second attempt was made too
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) || retryErr.Attempts != 2 {
+		t.Errorf("Upload error = %v, want 2 attempts", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("server got %d requests, want 2", n)
+	}
+}
+
+func TestUploadCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var requests atomic.Int32
+	release := make(chan struct{})
+	client, serverURL, _ := newTestUpload(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		cancel()
+		stall(r, release)
+	})
+	t.Cleanup(func() { close(release) })
+
+	err := Upload(ctx, client, serverURL, []byte("message"), UploadOptions{Policy: fastRetries(3)})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Upload error = %v, want context.Canceled", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("server got %d requests, want 1 as a cancelled upload is not retried", n)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"network error", errors.New("connection reset"), true},
+		{"timeout", &TimeoutError{After: time.Second}, true},
+		{"proxy down", &ProxyError{Address: DefaultTorProxy, Err: errors.New("refused")}, true},
+		{"cancelled", context.Canceled, false},
+		{"bad request", &StatusError{StatusCode: http.StatusBadRequest}, false},
+		{"unavailable", &StatusError{StatusCode: http.StatusServiceUnavailable}, true},
+	}
+
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
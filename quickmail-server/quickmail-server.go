@@ -9,6 +9,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/mail"
 	"net/smtp"
 	"os"
 	"strings"
@@ -17,6 +18,10 @@ import (
 
 const (
 	crlf = "\r\n"
+
+	// bccHeader carries the Bcc recipients outside the message, so they
+	// never appear in the delivered headers
+	bccHeader = "X-QuickMail-Bcc"
 )
 
 var (
@@ -51,6 +56,7 @@ func main() {
 	log.Printf("Using Message-ID domain: %s", messageIDDomain)
 
 	http.HandleFunc("/upload", handleUpload)
+	http.HandleFunc("/ping", handlePing)
 	fmt.Printf("Server running on http://localhost:8088 - forwarding messages to local Postfix\n")
 	log.Fatal(http.ListenAndServe(":8088", nil))
 }
@@ -178,6 +184,7 @@ func modifyHeaders(original []byte) []byte {
 
     inSubject := false
     inReferences := false
+    inBcc := false
 
     for scanner.Scan() {
         line := scanner.Text()
@@ -189,6 +196,9 @@ func modifyHeaders(original []byte) []byte {
         isFolded := len(line) > 0 && (line[0] == ' ' || line[0] == '\t')
         
         if isFolded {
+            if inBcc {
+                continue
+            }
             if inSubject {
                 subjectHeader.WriteString(crlf + line)
                 continue
@@ -202,6 +212,7 @@ func modifyHeaders(original []byte) []byte {
 
         inSubject = false
         inReferences = false
+        inBcc = false
         
         lowerLine := strings.ToLower(line)
         
@@ -219,6 +230,11 @@ func modifyHeaders(original []byte) []byte {
             continue
         }
 
+        if strings.HasPrefix(lowerLine, "bcc:") {
+            inBcc = true
+            continue
+        }
+
         if strings.HasPrefix(lowerLine, "from:") || 
            strings.HasPrefix(lowerLine, "message-id:") || 
            strings.HasPrefix(lowerLine, "date:") {
@@ -276,6 +292,14 @@ func normalizeLineEndings(data []byte) []byte {
 	return data
 }
 
+func handlePing(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	fmt.Fprint(w, "OK")
+}
+
 func handleUpload(w http.ResponseWriter, r *http.Request) {
 	defer func() {
 		randomDelay := time.Duration(time.Now().UnixNano()%5000+1000) * time.Millisecond
@@ -304,19 +328,33 @@ func handleUpload(w http.ResponseWriter, r *http.Request) {
 	// Normalize line endings and modify headers
 	normalized := normalizeLineEndings(content)
 	modified := modifyHeaders(normalized)
-	forwardToPostfix(modified)
+	forwardToPostfix(modified, parseBcc(r.Header.Get(bccHeader)))
+}
+
+func parseBcc(value string) []string {
+    var bcc []string
+    for _, address := range strings.Split(value, ",") {
+        address = strings.TrimSpace(address)
+        if address != "" {
+            bcc = append(bcc, address)
+        }
+    }
+    return bcc
 }
 
-func forwardToPostfix(message []byte) {
-    recipient := extractRecipient(message)
-    if recipient == "" {
+func forwardToPostfix(message []byte, bcc []string) {
+    recipients := append(extractRecipients(message), bcc...)
+    if len(recipients) == 0 {
         log.Printf("Error: No recipient found in message")
         return
     }
 
-    if !isAllowed(recipient) {
-        log.Printf("Access denied for recipient: %s", recipient)
-        return
+    // One recipient that is not allowed rejects the whole message
+    for _, recipient := range recipients {
+        if !isAllowed(recipient) {
+            log.Printf("Access denied for recipient: %s", recipient)
+            return
+        }
     }
 
     host := "127.0.0.1"
@@ -347,9 +385,11 @@ func forwardToPostfix(message []byte) {
     }
     
     // RCPT TO
-    if err := client.Rcpt(recipient); err != nil {
-        log.Printf("Error setting RCPT TO %s: %v", recipient, err)
-        return
+    for _, recipient := range recipients {
+        if err := client.Rcpt(recipient); err != nil {
+            log.Printf("Error setting RCPT TO %s: %v", recipient, err)
+            return
+        }
     }
 
     // DATA
@@ -374,6 +414,39 @@ func forwardToPostfix(message []byte) {
     
 }
 
+// extractRecipients returns the addresses from the To and Cc headers,
+// falling back to extractRecipient if they cannot be parsed
+func extractRecipients(message []byte) []string {
+    msg, err := mail.ReadMessage(bytes.NewReader(message))
+    if err != nil {
+        if recipient := extractRecipient(message); recipient != "" {
+            return []string{recipient}
+        }
+        return nil
+    }
+
+    var recipients []string
+    for _, field := range []string{"To", "Cc"} {
+        if msg.Header.Get(field) == "" {
+            continue
+        }
+        addresses, err := msg.Header.AddressList(field)
+        if err != nil {
+            log.Printf("Error parsing %s header: %v", field, err)
+            if field == "To" {
+                if recipient := extractRecipient(message); recipient != "" {
+                    recipients = append(recipients, recipient)
+                }
+            }
+            continue
+        }
+        for _, address := range addresses {
+            recipients = append(recipients, address.Address)
+        }
+    }
+    return recipients
+}
+
 func extractRecipient(message []byte) string {
 	scanner := bufio.NewScanner(bytes.NewReader(message))
 	for scanner.Scan() {